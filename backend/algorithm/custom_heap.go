@@ -7,7 +7,7 @@ import (
 
 type UserAmountItem struct {
 	userID    uuid.UUID
-	netAmount int
+	netAmount int64
 	index     int
 }
 
@@ -45,7 +45,7 @@ func (pq *PriorityQueue) Pop() interface{} {
 	return item
 }
 
-func (pq *PriorityQueue) Update(item *UserAmountItem, value uuid.UUID, priority int) {
+func (pq *PriorityQueue) Update(item *UserAmountItem, value uuid.UUID, priority int64) {
 	item.userID = value
 	item.netAmount = priority
 	heap.Fix(pq, item.index)
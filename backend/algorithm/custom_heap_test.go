@@ -15,7 +15,7 @@ func TestPriorityQueue_Push_Pop(t *testing.T) {
 	heap.Push(&pq, &UserAmountItem{netAmount: 3})
 	heap.Push(&pq, &UserAmountItem{netAmount: 7})
 
-	expectedOrder := []int{7, 5, 3, 1}
+	expectedOrder := []int64{7, 5, 3, 1}
 	for _, expectedAmt := range expectedOrder {
 		item := heap.Pop(&pq).(*UserAmountItem)
 		assert.Equal(t, item.netAmount, expectedAmt)
@@ -33,7 +33,7 @@ func TestPriorityQueue_Update(t *testing.T) {
 	heap.Push(&pq, &UserAmountItem{netAmount: 7})
 	pq.Update(itemToBeUpdated, itemToBeUpdated.userID, 3)
 
-	expectedOrder := []int{7, 5, 3, 1}
+	expectedOrder := []int64{7, 5, 3, 1}
 	for _, expectedAmt := range expectedOrder {
 		item := heap.Pop(&pq).(*UserAmountItem)
 		assert.Equal(t, item.netAmount, expectedAmt)
@@ -60,7 +60,7 @@ func (s *Simplifier) greedyAlgorithm(items []models.Item) []models.SimplifiedIte
 		return []models.SimplifiedItem{}
 	}
 
-	balances := map[uuid.UUID]int{}
+	balances := map[uuid.UUID]int64{}
 	for _, item := range items {
 		if item.ToUserID == item.FromUserID {
 			continue
@@ -4,7 +4,7 @@ import (
 	"backend/models"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
-	"sort"
+	"slices"
 	"testing"
 )
 
@@ -24,8 +24,8 @@ func TestSimplifier_greedyAlgorithm(t *testing.T) {
 		{FromUserID: uids[1], ToUserID: uids[2], Amount: 50},
 	}
 
-	expectedAmounts := []int{30, 40}
-	actualAmounts := []int{}
+	expectedAmounts := []int64{30, 40}
+	actualAmounts := []int64{}
 
 	simplifiedItems := s.greedyAlgorithm(items)
 	for _, item := range simplifiedItems {
@@ -33,7 +33,7 @@ func TestSimplifier_greedyAlgorithm(t *testing.T) {
 		//log.Println(item.Amount, "From user:", uidLookup[item.FromUserID], "To user:", uidLookup[item.ToUserID])
 	}
 
-	sort.Ints(actualAmounts)
-	sort.Ints(expectedAmounts)
+	slices.Sort(actualAmounts)
+	slices.Sort(expectedAmounts)
 	assert.ElementsMatch(t, expectedAmounts, actualAmounts)
 }
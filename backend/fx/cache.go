@@ -0,0 +1,109 @@
+package fx
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// currentSkew is how close to the clock a query's date must be for it to ask
+// for the current rate.
+const currentSkew = time.Minute
+
+// CachingProvider memoises the answers of Next per room and currency pair for
+// TTL. A cached quote only answers dates from when its rate took effect up to
+// the latest date it was the answer for, so a rate that took effect later in
+// the same day is not hidden. A quote fetched for the current time also
+// answers later current queries until it expires. Fallback quotes are not
+// cached, as the rate they stand in for may be published at any time.
+type CachingProvider struct {
+	Next RateProvider
+	TTL  time.Duration
+
+	mu      sync.Mutex
+	entries map[cacheKey][]cacheEntry
+}
+
+type cacheKey struct {
+	roomID uuid.UUID
+	from   string
+	to     string
+}
+
+type cacheEntry struct {
+	quote Quote
+	// asOf is the latest query date the quote answered.
+	asOf    time.Time
+	current bool
+	expires time.Time
+}
+
+func NewCachingProvider(next RateProvider, ttl time.Duration) *CachingProvider {
+	return &CachingProvider{Next: next, TTL: ttl, entries: map[cacheKey][]cacheEntry{}}
+}
+
+func (e cacheEntry) answers(date time.Time, now time.Time) bool {
+	if !now.Before(e.expires) || date.Before(e.quote.Date) {
+		return false
+	}
+	return !date.After(e.asOf) || (e.current && !date.After(now.Add(currentSkew)))
+}
+
+func (c *CachingProvider) Rate(q Query) (Quote, error) {
+	key := cacheKey{roomID: q.RoomID, from: q.From, to: q.To}
+	now := time.Now()
+
+	c.mu.Lock()
+	for _, entry := range c.entries[key] {
+		if entry.answers(q.Date, now) {
+			c.mu.Unlock()
+			return entry.quote, nil
+		}
+	}
+	c.mu.Unlock()
+
+	quote, err := c.Next.Rate(q)
+	if err != nil || quote.Fallback {
+		return quote, err
+	}
+
+	entry := cacheEntry{
+		quote:   quote,
+		asOf:    q.Date,
+		current: now.Sub(q.Date) < currentSkew,
+		expires: now.Add(c.TTL),
+	}
+	c.mu.Lock()
+	entries := []cacheEntry{entry}
+	for _, old := range c.entries[key] {
+		if !now.Before(old.expires) {
+			continue
+		}
+		// answers with the same rate merge into one covering all their dates
+		if old.quote.Date.Equal(quote.Date) && !old.current {
+			if old.asOf.After(entries[0].asOf) {
+				entries[0].asOf = old.asOf
+			}
+			continue
+		}
+		entries = append(entries, old)
+	}
+	c.entries[key] = entries
+	c.mu.Unlock()
+	return quote, nil
+}
+
+func (c *CachingProvider) Invalidate(roomID uuid.UUID) {
+	c.mu.Lock()
+	for key := range c.entries {
+		if key.roomID == roomID {
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+
+	if invalidator, ok := c.Next.(Invalidator); ok {
+		invalidator.Invalidate(roomID)
+	}
+}
@@ -0,0 +1,199 @@
+package fx
+
+import (
+	"backend/models"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ecbBase is the currency every rate in an ECB reference file is quoted against.
+const ecbBase = "EUR"
+
+// FileProvider serves historical reference rates from a local file in one of
+// the formats published by the ECB: eurofxref-hist.csv or eurofxref-hist.xml.
+// The file is reloaded whenever its modification time changes, or on Reload.
+type FileProvider struct {
+	Path string
+
+	mu      sync.RWMutex
+	modTime time.Time
+	days    []ecbDay
+}
+
+type ecbDay struct {
+	date  time.Time
+	rates map[string]*big.Rat
+}
+
+func NewFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{Path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload re-reads the file unconditionally.
+func (p *FileProvider) Reload() error {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return err
+	}
+
+	var days []ecbDay
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+		days, err = parseECBXML(data)
+	} else {
+		days, err = parseECBCSV(data)
+	}
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", p.Path, err)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].date.Before(days[j].date) })
+
+	p.mu.Lock()
+	p.days = days
+	p.modTime = info.ModTime()
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *FileProvider) reloadIfChanged() {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return
+	}
+	p.mu.RLock()
+	changed := !info.ModTime().Equal(p.modTime)
+	p.mu.RUnlock()
+	if changed {
+		// keep serving the old rates if the new file is broken
+		p.Reload()
+	}
+}
+
+func (p *FileProvider) Rate(q Query) (Quote, error) {
+	if q.From == q.To {
+		return identityQuote(q), nil
+	}
+	p.reloadIfChanged()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	// latest published day on or before the requested date
+	target := q.Date.UTC()
+	i := sort.Search(len(p.days), func(i int) bool { return p.days[i].date.After(target) })
+	for i--; i >= 0; i-- {
+		day := p.days[i]
+		from, okFrom := day.rate(q.From)
+		to, okTo := day.rate(q.To)
+		if !okFrom || !okTo {
+			continue
+		}
+		return Quote{
+			From:     q.From,
+			To:       q.To,
+			Rate:     models.RateFromRat(new(big.Rat).Quo(to, from)),
+			Date:     day.date,
+			Source:   "file",
+			Fallback: day.date.Before(target.Truncate(24 * time.Hour)),
+		}, nil
+	}
+	return Quote{}, ErrRateNotFound
+}
+
+func (d ecbDay) rate(currency string) (*big.Rat, bool) {
+	if currency == ecbBase {
+		return big.NewRat(1, 1), true
+	}
+	rate, ok := d.rates[currency]
+	return rate, ok
+}
+
+// parseECBCSV reads "Date,USD,JPY,...\n2024-03-01,1.0833,162.24,..." where
+// missing rates are blank or N/A.
+func parseECBCSV(data []byte) ([]ecbDay, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 1 || !strings.EqualFold(strings.TrimSpace(rows[0][0]), "date") {
+		return nil, errors.New("missing Date header")
+	}
+
+	header := rows[0]
+	days := make([]ecbDay, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		date, err := time.Parse(time.DateOnly, strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q", row[0])
+		}
+		day := ecbDay{date: date, rates: map[string]*big.Rat{}}
+		for i := 1; i < len(row) && i < len(header); i++ {
+			currency := strings.ToUpper(strings.TrimSpace(header[i]))
+			if rate, ok := parsePositiveRat(row[i]); ok && currency != "" {
+				day.rates[currency] = rate
+			}
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+func parseECBXML(data []byte) ([]ecbDay, error) {
+	var envelope ecbEnvelope
+	if err := xml.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+
+	days := make([]ecbDay, 0, len(envelope.Days))
+	for _, cube := range envelope.Days {
+		date, err := time.Parse(time.DateOnly, cube.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q", cube.Time)
+		}
+		day := ecbDay{date: date, rates: map[string]*big.Rat{}}
+		for _, rate := range cube.Rates {
+			if r, ok := parsePositiveRat(rate.Rate); ok {
+				day.rates[strings.ToUpper(rate.Currency)] = r
+			}
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func parsePositiveRat(s string) (*big.Rat, bool) {
+	rate, err := models.ParseRate(s)
+	if err != nil {
+		return nil, false
+	}
+	r, err := rate.Rat()
+	return r, err == nil
+}
@@ -0,0 +1,71 @@
+package fx
+
+import (
+	"backend/models"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// Query asks for the rate converting From into To in effect on Date. RoomID
+// scopes providers that hold per-room rates; others ignore it.
+type Query struct {
+	RoomID uuid.UUID
+	From   string
+	To     string
+	Date   time.Time
+}
+
+// Quote is a rate answering a Query: one unit of From buys Rate units of To.
+// Date is when the rate was published or took effect, which can be earlier
+// than the date asked for. Fallback marks a rate from an earlier day standing
+// in for one not published for the day asked for.
+type Quote struct {
+	From     string      `json:"from"`
+	To       string      `json:"to"`
+	Rate     models.Rate `json:"rate"`
+	Date     time.Time   `json:"date"`
+	Source   string      `json:"source"`
+	Fallback bool        `json:"fallback,omitempty"`
+}
+
+type RateProvider interface {
+	Rate(q Query) (Quote, error)
+}
+
+// Invalidator is implemented by providers that cache rates of a room and
+// must drop them when the room's own rates change.
+type Invalidator interface {
+	Invalidate(roomID uuid.UUID)
+}
+
+// ChainProvider asks each provider in turn and returns the first rate found.
+type ChainProvider []RateProvider
+
+func (c ChainProvider) Rate(q Query) (Quote, error) {
+	for _, provider := range c {
+		quote, err := provider.Rate(q)
+		if err == nil {
+			return quote, nil
+		}
+		if !errors.Is(err, ErrRateNotFound) {
+			return Quote{}, err
+		}
+	}
+	return Quote{}, ErrRateNotFound
+}
+
+func (c ChainProvider) Invalidate(roomID uuid.UUID) {
+	for _, provider := range c {
+		if invalidator, ok := provider.(Invalidator); ok {
+			invalidator.Invalidate(roomID)
+		}
+	}
+}
+
+func identityQuote(q Query) Quote {
+	return Quote{From: q.From, To: q.To, Rate: "1", Date: q.Date, Source: "identity"}
+}
@@ -0,0 +1,184 @@
+package fx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCSV = `Date,USD,JPY,GBP,
+2024-03-01,1.0833,162.24,0.8556,
+2024-02-29,1.0813,N/A,0.8562,
+`
+
+const testXML = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube>
+		<Cube time="2024-03-01">
+			<Cube currency="USD" rate="1.0833"/>
+			<Cube currency="GBP" rate="0.8556"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func writeRates(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func date(s string) time.Time {
+	d, _ := time.Parse(time.DateOnly, s)
+	return d
+}
+
+func TestFileProvider_CSV(t *testing.T) {
+	p, err := NewFileProvider(writeRates(t, "rates.csv", testCSV))
+	assert.NoError(t, err)
+
+	quote, err := p.Rate(Query{From: "EUR", To: "USD", Date: date("2024-03-01")})
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0833", string(quote.Rate))
+	assert.Equal(t, "file", quote.Source)
+	assert.False(t, quote.Fallback)
+
+	// cross rate through EUR
+	quote, err = p.Rate(Query{From: "GBP", To: "USD", Date: date("2024-02-29")})
+	assert.NoError(t, err)
+	assert.Equal(t, "1.262905863116", string(quote.Rate))
+}
+
+func TestFileProvider_UsesLastPublishedDay(t *testing.T) {
+	p, err := NewFileProvider(writeRates(t, "rates.csv", testCSV))
+	assert.NoError(t, err)
+
+	// Saturday falls back to Friday
+	quote, err := p.Rate(Query{From: "EUR", To: "USD", Date: date("2024-03-02")})
+	assert.NoError(t, err)
+	assert.Equal(t, date("2024-03-01"), quote.Date)
+	assert.True(t, quote.Fallback)
+
+	// JPY is missing on the 29th and nothing older exists
+	_, err = p.Rate(Query{From: "EUR", To: "JPY", Date: date("2024-02-29")})
+	assert.ErrorIs(t, err, ErrRateNotFound)
+
+	_, err = p.Rate(Query{From: "EUR", To: "USD", Date: date("2024-01-01")})
+	assert.ErrorIs(t, err, ErrRateNotFound)
+}
+
+func TestFileProvider_XML(t *testing.T) {
+	p, err := NewFileProvider(writeRates(t, "rates.xml", testXML))
+	assert.NoError(t, err)
+
+	quote, err := p.Rate(Query{From: "USD", To: "EUR", Date: date("2024-03-05")})
+	assert.NoError(t, err)
+	assert.Equal(t, "0.923105326318", string(quote.Rate))
+}
+
+func TestFileProvider_ReloadsChangedFile(t *testing.T) {
+	path := writeRates(t, "rates.csv", testCSV)
+	p, err := NewFileProvider(path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(path, []byte("Date,USD\n2024-03-01,2\n"), 0o644))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+
+	quote, err := p.Rate(Query{From: "EUR", To: "USD", Date: date("2024-03-01")})
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(quote.Rate))
+}
+
+type stubProvider struct {
+	quotes map[string]Quote
+	calls  int
+}
+
+func (s *stubProvider) Rate(q Query) (Quote, error) {
+	s.calls++
+	quote, ok := s.quotes[q.From+q.To]
+	if !ok {
+		return Quote{}, ErrRateNotFound
+	}
+	return quote, nil
+}
+
+func TestChainProvider_FirstMatchWins(t *testing.T) {
+	manual := &stubProvider{quotes: map[string]Quote{"USDEUR": {Rate: "0.9", Source: "manual"}}}
+	file := &stubProvider{quotes: map[string]Quote{"USDEUR": {Rate: "0.92"}, "GBPEUR": {Rate: "1.17", Source: "file"}}}
+	chain := ChainProvider{manual, file}
+
+	quote, err := chain.Rate(Query{From: "USD", To: "EUR"})
+	assert.NoError(t, err)
+	assert.Equal(t, "manual", quote.Source)
+
+	quote, err = chain.Rate(Query{From: "GBP", To: "EUR"})
+	assert.NoError(t, err)
+	assert.Equal(t, "file", quote.Source)
+
+	_, err = chain.Rate(Query{From: "JPY", To: "EUR"})
+	assert.ErrorIs(t, err, ErrRateNotFound)
+}
+
+func TestCachingProvider(t *testing.T) {
+	day := date("2024-03-01")
+	next := &stubProvider{quotes: map[string]Quote{"USDEUR": {Rate: "0.9", Date: day.Add(8 * time.Hour)}}}
+	cache := NewCachingProvider(next, time.Hour)
+	roomID := uuid.New()
+	q := Query{RoomID: roomID, From: "USD", To: "EUR", Date: day.Add(14 * time.Hour)}
+
+	cache.Rate(q)
+	// any date between the rate taking effect and the date asked for
+	q.Date = day.Add(9 * time.Hour)
+	quote, err := cache.Rate(q)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.9", string(quote.Rate))
+	assert.Equal(t, 1, next.calls)
+
+	// later in the day another rate may have taken effect
+	q.Date = day.Add(15 * time.Hour)
+	cache.Rate(q)
+	assert.Equal(t, 2, next.calls)
+	q.Date = day.Add(7 * time.Hour)
+	cache.Rate(q)
+	assert.Equal(t, 3, next.calls)
+
+	q.Date = day.Add(15 * time.Hour)
+	cache.Invalidate(uuid.New())
+	cache.Rate(q)
+	assert.Equal(t, 3, next.calls)
+
+	cache.Invalidate(roomID)
+	cache.Rate(q)
+	assert.Equal(t, 4, next.calls)
+
+	// misses are not cached
+	cache.Rate(Query{RoomID: roomID, From: "GBP", To: "EUR"})
+	cache.Rate(Query{RoomID: roomID, From: "GBP", To: "EUR"})
+	assert.Equal(t, 6, next.calls)
+}
+
+func TestCachingProvider_Current(t *testing.T) {
+	next := &stubProvider{quotes: map[string]Quote{"USDEUR": {Rate: "0.9", Date: date("2024-03-01")}}}
+	cache := NewCachingProvider(next, time.Hour)
+	q := Query{From: "USD", To: "EUR", Date: time.Now()}
+
+	cache.Rate(q)
+	q.Date = time.Now()
+	cache.Rate(q)
+	assert.Equal(t, 1, next.calls)
+}
+
+func TestCachingProvider_SkipsFallbacks(t *testing.T) {
+	next := &stubProvider{quotes: map[string]Quote{"USDEUR": {Rate: "0.9", Date: date("2024-03-01"), Fallback: true}}}
+	cache := NewCachingProvider(next, time.Hour)
+	q := Query{From: "USD", To: "EUR", Date: date("2024-03-02")}
+
+	cache.Rate(q)
+	cache.Rate(q)
+	assert.Equal(t, 2, next.calls)
+}
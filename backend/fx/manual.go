@@ -0,0 +1,46 @@
+package fx
+
+import (
+	"backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ManualProvider serves the rates members recorded for their room. A rate
+// recorded as Currency -> BaseCurrency also answers the inverse query.
+type ManualProvider struct {
+	DB *gorm.DB
+}
+
+func NewManualProvider(db *gorm.DB) *ManualProvider {
+	return &ManualProvider{DB: db}
+}
+
+func (p *ManualProvider) Rate(q Query) (Quote, error) {
+	if q.From == q.To {
+		return identityQuote(q), nil
+	}
+
+	var rates []models.ExchangeRate
+	if err := p.DB.Where("room_id = ? AND effective_at <= ?", q.RoomID, q.Date).
+		Where("(currency = ? AND base_currency = ?) OR (currency = ? AND base_currency = ?)", q.From, q.To, q.To, q.From).
+		Order("effective_at DESC").
+		Limit(1).
+		Find(&rates).Error; err != nil {
+		return Quote{}, err
+	}
+	if len(rates) == 0 {
+		return Quote{}, ErrRateNotFound
+	}
+
+	rate := rates[0]
+	quote := Quote{From: q.From, To: q.To, Rate: rate.Rate, Date: rate.EffectiveAt, Source: "manual"}
+	if rate.Currency != q.From {
+		inverse, err := rate.Rate.Inverse()
+		if err != nil {
+			return Quote{}, err
+		}
+		quote.Rate = inverse
+	}
+	return quote, nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	ActivityItemsCreated       = "items_created"
+	ActivityItemUpdated        = "item_updated"
+	ActivityItemsDeleted       = "items_deleted"
+	ActivitySettlementRecorded = "settlement_recorded"
+	ActivityMemberJoined       = "member_joined"
+	ActivityMemberRequested    = "member_requested"
+	ActivityMemberApproved     = "member_approved"
+	ActivityMemberRejected     = "member_rejected"
+	ActivityMemberLeft         = "member_left"
+	ActivityPlaceholderAdded   = "placeholder_added"
+	ActivityPlaceholderClaimed = "placeholder_claimed"
+	ActivityRoleChanged        = "role_changed"
+	ActivityHouseholdChanged   = "household_changed"
+	ActivitySettingsChanged    = "settings_changed"
+	ActivityBaseCurrency       = "base_currency_changed"
+	ActivityRevaluationRevert  = "revaluation_reverted"
+	ActivityRoomArchived       = "room_archived"
+	ActivityRoomReopened       = "room_reopened"
+	ActivityRoomCloned         = "room_cloned"
+)
+
+// recordActivity appends an entry to the room's audit log. Failing to log
+// does not undo the change it describes.
+func (h *Handler) recordActivity(roomID uuid.UUID, actorID uuid.UUID, action string, before interface{}, after interface{}) {
+	entry := models.RoomAuditEntry{RoomID: roomID, ActorID: actorID, Action: action}
+
+	var err error
+	if entry.Before, err = models.NewJSONText(before); err == nil {
+		entry.After, err = models.NewJSONText(after)
+	}
+	if err == nil {
+		err = h.DB.Create(&entry).Error
+	}
+	if err != nil {
+		log.Printf("failed to record %s activity for room %s: %v", action, roomID, err)
+	}
+}
+
+// GetActivity pages through the room's audit log, newest first. It can be
+// narrowed to one action or one actor.
+func (h *Handler) GetActivity(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomForMember(w, r, ps)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	query := h.DB.Where("room_id = ?", room.ID)
+	if s := q.Get("action"); s != "" {
+		query = query.Where("action = ?", strings.ToLower(s))
+	}
+	if s := q.Get("actor"); s != "" {
+		actorID, err := uuid.Parse(s)
+		if err != nil {
+			http.Error(w, "INVALID_USER_ID", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("actor_id = ?", actorID)
+	}
+
+	limit := DefaultItemPageSize
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			http.Error(w, "INVALID_LIMIT", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, MaxItemPageSize)
+	}
+	if s := q.Get("cursor"); s != "" {
+		cursor, err := decodeItemCursor(s)
+		if err != nil {
+			http.Error(w, "INVALID_CURSOR", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("(created_at, id) < (?, ?)", cursor.OccurredAt, cursor.ID)
+	}
+
+	// fetch one extra row to know whether another page exists
+	activities := []models.RoomAuditEntry{}
+	if err := query.Order("created_at DESC, id DESC").Limit(limit + 1).Find(&activities).Error; err != nil {
+		http.Error(w, "DB_ERROR_ACTIVITIES", http.StatusInternalServerError)
+		return
+	}
+
+	var nextCursor string
+	if len(activities) > limit {
+		activities = activities[:limit]
+		last := activities[len(activities)-1]
+		nextCursor = encodeItemCursor(itemCursor{OccurredAt: last.CreatedAt, ID: last.ID})
+	}
+
+	response := map[string]interface{}{
+		"activities": activities,
+		"nextCursor": nextCursor,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
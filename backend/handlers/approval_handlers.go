@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const (
+	ApprovalApproved = "APPROVED"
+	ApprovalDisputed = "DISPUTED"
+	ApprovalResolved = "RESOLVED"
+)
+
+type DisputeItemRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ResolveItemRequest struct {
+	// Amount optionally corrects the disputed amount before resubmitting.
+	// Foreign-currency items are corrected through ForeignAmount instead,
+	// which is converted at the item's recorded rate.
+	Amount        *int64     `json:"amount"`
+	ForeignAmount *int64     `json:"foreign_amount"`
+	Content       *string    `json:"content"`
+	OccurredAt    *time.Time `json:"occurred_at"`
+}
+
+// GetApprovals lists the pending and disputed items in the room that either
+// charge the caller or were created by them.
+func (h *Handler) GetApprovals(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if !h.requireRoomMember(w, roomID, userID) {
+		return
+	}
+
+	var items []models.Item
+	if err := h.DB.Where("room_id = ? AND status IN ?", roomID, []string{ItemPending, ItemDisputed}).
+		Where("from_user_id = ? OR creator_id = ?", userID, userID).
+		Order("occurred_at ASC").
+		Find(&items).Error; err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"awaitingMe":     filterItems(items, func(item models.Item) bool { return item.FromUserID == userID && item.Status == ItemPending }),
+		"awaitingOthers": filterItems(items, func(item models.Item) bool { return item.CreatorID == userID && item.Status == ItemPending }),
+		"disputed":       filterItems(items, func(item models.Item) bool { return item.Status == ItemDisputed }),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *Handler) ApproveItem(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	item, _, ok := h.loadItemForApproval(w, r, ps)
+	if !ok {
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if item.FromUserID != userID {
+		http.Error(w, "NOT_CHARGED_USER", http.StatusForbidden)
+		return
+	}
+	if item.Status != ItemPending && item.Status != ItemDisputed {
+		http.Error(w, "ITEM_NOT_PENDING", http.StatusConflict)
+		return
+	}
+
+	item.Status = ItemApproved
+	item.DisputeReason = ""
+	h.saveApprovalChange(w, item, &ApprovalEvent{
+		Action:  ApprovalApproved,
+		ItemID:  item.ID,
+		ActorID: userID,
+		Status:  item.Status,
+	})
+}
+
+func (h *Handler) DisputeItem(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	item, _, ok := h.loadItemForApproval(w, r, ps)
+	if !ok {
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if item.FromUserID != userID {
+		http.Error(w, "NOT_CHARGED_USER", http.StatusForbidden)
+		return
+	}
+	if item.Status != ItemPending {
+		http.Error(w, "ITEM_NOT_PENDING", http.StatusConflict)
+		return
+	}
+
+	var req DisputeItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+	if len(req.Reason) > 500 {
+		http.Error(w, "REASON_TOO_LONG", http.StatusBadRequest)
+		return
+	}
+
+	item.Status = ItemDisputed
+	item.DisputeReason = req.Reason
+	h.saveApprovalChange(w, item, &ApprovalEvent{
+		Action:  ApprovalDisputed,
+		ItemID:  item.ID,
+		ActorID: userID,
+		Status:  item.Status,
+		Reason:  req.Reason,
+	})
+}
+
+// ResolveItem lets the creator of a disputed item, or an admin, correct it and
+// send it back for approval. Withdrawing an item is done by deleting it.
+func (h *Handler) ResolveItem(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	item, roomUser, ok := h.loadItemForApproval(w, r, ps)
+	if !ok {
+		return
+	}
+
+	userID := roomUser.UserID
+	if !canEditItem(roomUser, item) {
+		http.Error(w, "NOT_ITEM_CREATOR", http.StatusForbidden)
+		return
+	}
+	if item.Status != ItemDisputed {
+		http.Error(w, "ITEM_NOT_DISPUTED", http.StatusConflict)
+		return
+	}
+
+	var req ResolveItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+	if req.Amount != nil {
+		if *req.Amount <= 0 {
+			http.Error(w, "INVALID_AMOUNT", http.StatusBadRequest)
+			return
+		}
+		if item.ForeignCurrency != "" {
+			http.Error(w, "FOREIGN_AMOUNT_REQUIRED", http.StatusBadRequest)
+			return
+		}
+		item.Amount = *req.Amount
+	}
+	if req.ForeignAmount != nil {
+		if item.ForeignCurrency == "" {
+			http.Error(w, "NOT_A_FOREIGN_ITEM", http.StatusBadRequest)
+			return
+		}
+		if *req.ForeignAmount <= 0 {
+			http.Error(w, "INVALID_FOREIGN_AMOUNT", http.StatusBadRequest)
+			return
+		}
+		item.ForeignAmount = *req.ForeignAmount
+	}
+	if req.Content != nil {
+		item.Content = *req.Content
+	}
+
+	var room models.Room
+	if req.ForeignAmount != nil || req.OccurredAt != nil {
+		if err := h.DB.First(&room, "id = ?", item.RoomID).Error; err != nil {
+			http.Error(w, "ROOM_NOT_FOUND", http.StatusNotFound)
+			return
+		}
+	}
+	redated := false
+	if req.OccurredAt != nil {
+		if err := checkOccurredAt(&room, *req.OccurredAt, time.Now()); err != nil {
+			writeError(w, err, "INVALID_OCCURRED_AT")
+			return
+		}
+		redated = !item.OccurredAt.Equal(*req.OccurredAt)
+		item.OccurredAt = *req.OccurredAt
+	}
+
+	switch {
+	case item.ForeignCurrency == "":
+	case redated:
+		// a new date takes the rate that was in effect on that date
+		items := []models.Item{item}
+		if err := h.convertForeignItems(&room, items); err != nil {
+			writeError(w, err, "DB_ERROR_EXCHANGE_RATES")
+			return
+		}
+		item = items[0]
+	case req.ForeignAmount != nil:
+		amount, err := models.Money{Amount: item.ForeignAmount, Currency: item.ForeignCurrency}.Convert(item.FxRate, room.BaseCurrency)
+		if err != nil {
+			http.Error(w, "FX_CONVERSION_FAILED", http.StatusBadRequest)
+			return
+		}
+		item.Amount = amount.Amount
+	}
+
+	item.Status = ItemPending
+	item.DisputeReason = ""
+	h.saveApprovalChange(w, item, &ApprovalEvent{
+		Action:  ApprovalResolved,
+		ItemID:  item.ID,
+		ActorID: userID,
+		Status:  item.Status,
+	})
+}
+
+func (h *Handler) loadItemForApproval(w http.ResponseWriter, r *http.Request, ps httprouter.Params) (models.Item, models.RoomUser, bool) {
+	var item models.Item
+
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return item, models.RoomUser{}, false
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	roomUser, ok := h.requireRoomPermission(w, roomID, userID, PermViewRoom)
+	if !ok {
+		return item, roomUser, false
+	}
+
+	if err := h.DB.Where("id = ? AND room_id = ?", ps.ByName("itemID"), roomID).First(&item).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "ITEM_NOT_FOUND", http.StatusNotFound)
+		} else {
+			http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		}
+		return item, roomUser, false
+	}
+	// settlements still need approving once the room is archived
+	if item.TransactionType != Transfer && !h.requireRoomOpen(w, roomID) {
+		return item, roomUser, false
+	}
+	return item, roomUser, true
+}
+
+// saveApprovalChange persists the new item state, recomputes the settlement
+// plan and notifies everyone else in the room.
+func (h *Handler) saveApprovalChange(w http.ResponseWriter, item models.Item, event *ApprovalEvent) {
+	var before models.Item
+	if err := h.DB.First(&before, "id = ?", item.ID).Error; err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+	if err := h.DB.Model(&item).Select("status", "dispute_reason", "amount", "foreign_amount", "fx_rate", "content", "occurred_at").Updates(&item).Error; err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+
+	simplifiedItems, _ := h.resimplify(item.RoomID)
+	h.recordActivity(item.RoomID, event.ActorID, ActivityItemUpdated, before, item)
+
+	h.pushUpdatesToOtherClients(item.RoomID.String(), event.ActorID.String(), &SSEUpdateInfo{
+		UpdatedItems:    []models.Item{item},
+		SimplifiedItems: simplifiedItems,
+		Approval:        event,
+	})
+	h.checkBudgets(item.RoomID, []models.Item{item})
+
+	response := map[string]interface{}{
+		"item":            item,
+		"simplifiedItems": simplifiedItems,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func filterItems(items []models.Item, keep func(models.Item) bool) []models.Item {
+	res := []models.Item{}
+	for _, item := range items {
+		if keep(item) {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"backend/algorithm"
+	"backend/fx"
+	"backend/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testHandler connects to the Postgres database named by TEST_DATABASE_URL,
+// skipping the test when none is configured.
+func testHandler(t *testing.T) *Handler {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, db.AutoMigrate(&models.Room{}, &models.Item{}, &models.RoomUser{}, &models.Household{}, &models.Budget{}, &models.BudgetAlert{}, &models.ExchangeRate{})) {
+		t.FailNow()
+	}
+	return &Handler{
+		DB:                    db,
+		Simplifier:            &algorithm.Simplifier{},
+		RoomClients:           &sync.Map{},
+		RoomToSimplifiedItems: &sync.Map{},
+		RoomToHouseholdItems:  &sync.Map{},
+		Rates:                 fx.NewManualProvider(db),
+	}
+}
+
+func TestResolveItem_ForeignCurrency(t *testing.T) {
+	h := testHandler(t)
+
+	room := models.Room{
+		BaseCurrency:      "EUR",
+		ForeignCurrencies: models.CurrencyList{"USD"},
+		CreatedAt:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	assert.NoError(t, h.DB.Create(&room).Error)
+	t.Cleanup(func() {
+		h.DB.Delete(&models.Item{}, "room_id = ?", room.ID)
+		h.DB.Delete(&models.ExchangeRate{}, "room_id = ?", room.ID)
+		h.DB.Delete(&models.RoomUser{}, "room_id = ?", room.ID)
+		h.DB.Delete(&room)
+	})
+
+	creatorID := uuid.New()
+	assert.NoError(t, h.DB.Create(&models.RoomUser{RoomID: room.ID, UserID: creatorID, Status: "IN"}).Error)
+	for _, rate := range []models.ExchangeRate{
+		{RoomID: room.ID, Currency: "USD", BaseCurrency: "EUR", Rate: "0.9", EffectiveAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{RoomID: room.ID, Currency: "USD", BaseCurrency: "EUR", Rate: "0.8", EffectiveAt: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)},
+	} {
+		assert.NoError(t, h.DB.Create(&rate).Error)
+	}
+
+	item := models.Item{
+		RoomID:          room.ID,
+		GroupID:         uuid.New(),
+		FromUserID:      uuid.New(),
+		ToUserID:        creatorID,
+		CreatorID:       creatorID,
+		Amount:          900,
+		ForeignAmount:   1000,
+		ForeignCurrency: "USD",
+		FxRate:          "0.9",
+		TransactionType: Expense,
+		Status:          ItemDisputed,
+		OccurredAt:      time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+	}
+	assert.NoError(t, h.DB.Create(&item).Error)
+
+	resolve := func(body string) models.Item {
+		assert.NoError(t, h.DB.Model(&item).Update("status", ItemDisputed).Error)
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), "userID", creatorID))
+		w := httptest.NewRecorder()
+		h.ResolveItem(w, r, httprouter.Params{{Key: "roomID", Value: room.ID.String()}, {Key: "itemID", Value: item.ID.String()}})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var saved models.Item
+		assert.NoError(t, h.DB.First(&saved, "id = ?", item.ID).Error)
+		return saved
+	}
+
+	// a corrected amount keeps the rate the item was recorded at
+	saved := resolve(`{"foreign_amount": 1500}`)
+	assert.Equal(t, int64(1500), saved.ForeignAmount)
+	assert.Equal(t, int64(1350), saved.Amount)
+	assert.Equal(t, models.Rate("0.9"), saved.FxRate)
+	assert.Equal(t, ItemPending, saved.Status)
+
+	// a new date takes the rate in effect on that date
+	saved = resolve(`{"foreign_amount": 2000, "occurred_at": "2024-03-12T00:00:00Z"}`)
+	assert.Equal(t, int64(2000), saved.ForeignAmount)
+	assert.Equal(t, int64(1600), saved.Amount)
+	assert.Equal(t, models.Rate("0.8"), saved.FxRate)
+	assert.True(t, saved.OccurredAt.Equal(time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)))
+
+	resp := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"amount": 100}`))
+	r = r.WithContext(context.WithValue(r.Context(), "userID", creatorID))
+	assert.NoError(t, h.DB.Model(&item).Update("status", ItemDisputed).Error)
+	h.ResolveItem(resp, r, httprouter.Params{{Key: "roomID", Value: room.ID.String()}, {Key: "itemID", Value: item.ID.String()}})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, "FOREIGN_AMOUNT_REQUIRED\n", resp.Body.String())
+}
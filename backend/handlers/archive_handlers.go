@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const (
+	RoomArchivedEvent = "room_archived"
+	RoomReopenedEvent = "room_reopened"
+)
+
+// ArchiveRoom freezes the room and records its final balances and settlement
+// plan. Until it is reopened, members can only record settlements.
+func (h *Handler) ArchiveRoom(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermArchiveRoom)
+	if !ok {
+		return
+	}
+	if room.ArchivedAt != nil {
+		http.Error(w, "ROOM_ARCHIVED", http.StatusConflict)
+		return
+	}
+	userID := r.Context().Value("userID").(uuid.UUID)
+
+	// approvals are closed in an archived room, so nothing may be left waiting
+	var unresolved int64
+	if err := h.DB.Model(&models.Item{}).
+		Where("room_id = ? AND status IN ?", room.ID, []string{ItemPending, ItemDisputed}).
+		Count(&unresolved).Error; err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+	if unresolved > 0 {
+		http.Error(w, "PENDING_ITEMS", http.StatusConflict)
+		return
+	}
+
+	balances, err := h.roomBalances(room.ID)
+	if err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+	simplifiedItems, err := h.resimplify(room.ID)
+	if err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+
+	snapshot := models.RoomSnapshot{
+		RoomID:      room.ID,
+		Currency:    room.BaseCurrency,
+		CreatorID:   userID,
+		Balances:    []models.SnapshotBalance{},
+		Settlements: []models.SnapshotSettlement{},
+	}
+	for memberID, balance := range balances {
+		snapshot.Balances = append(snapshot.Balances, models.SnapshotBalance{UserID: memberID, Balance: balance})
+	}
+	sort.Slice(snapshot.Balances, func(i, j int) bool {
+		return snapshot.Balances[i].UserID.String() < snapshot.Balances[j].UserID.String()
+	})
+	for _, item := range simplifiedItems {
+		snapshot.Settlements = append(snapshot.Settlements, models.SnapshotSettlement{
+			FromUserID: item.FromUserID,
+			ToUserID:   item.ToUserID,
+			Amount:     item.Amount,
+		})
+	}
+
+	now := time.Now()
+	entry := models.RoomAuditEntry{RoomID: room.ID, ActorID: userID, Action: ActivityRoomArchived, Field: "archived", OldValue: "false", NewValue: "true"}
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&snapshot).Error; err != nil {
+			return err
+		}
+		var err error
+		if entry.After, err = models.NewJSONText(snapshot); err != nil {
+			return err
+		}
+		if err := tx.Model(&room).Updates(map[string]interface{}{"archived_at": now, "archived_by": userID}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&entry).Error
+	}); err != nil {
+		http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		return
+	}
+	room.ArchivedAt, room.ArchivedBy = &now, &userID
+
+	h.pushUpdatesToAllClients(room.ID.String(), &SSEUpdateInfo{
+		Event:        RoomArchivedEvent,
+		Room:         &room,
+		Snapshot:     &snapshot,
+		AuditEntries: []models.RoomAuditEntry{entry},
+	})
+
+	response := map[string]interface{}{
+		"room":     room,
+		"snapshot": snapshot,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ReopenRoom makes an archived room editable again. Its snapshots are kept.
+func (h *Handler) ReopenRoom(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermArchiveRoom)
+	if !ok {
+		return
+	}
+	if room.ArchivedAt == nil {
+		http.Error(w, "ROOM_NOT_ARCHIVED", http.StatusConflict)
+		return
+	}
+	userID := r.Context().Value("userID").(uuid.UUID)
+
+	entry := models.RoomAuditEntry{RoomID: room.ID, ActorID: userID, Action: ActivityRoomReopened, Field: "archived", OldValue: "true", NewValue: "false"}
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&room).Updates(map[string]interface{}{"archived_at": nil, "archived_by": nil}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&entry).Error
+	}); err != nil {
+		http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		return
+	}
+	room.ArchivedAt, room.ArchivedBy = nil, nil
+
+	h.pushUpdatesToAllClients(room.ID.String(), &SSEUpdateInfo{
+		Event:        RoomReopenedEvent,
+		Room:         &room,
+		AuditEntries: []models.RoomAuditEntry{entry},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room)
+}
+
+// GetRoomSnapshots lists the snapshots taken each time the room was archived,
+// newest first.
+func (h *Handler) GetRoomSnapshots(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomForMember(w, r, ps)
+	if !ok {
+		return
+	}
+
+	snapshots := []models.RoomSnapshot{}
+	if err := h.DB.Preload("Balances").Preload("Settlements").
+		Where("room_id = ?", room.ID).
+		Order("created_at DESC").
+		Find(&snapshots).Error; err != nil {
+		http.Error(w, "DB_ERROR_SNAPSHOTS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+// requireRoomOpen writes an error response and returns false if roomID is
+// archived.
+func (h *Handler) requireRoomOpen(w http.ResponseWriter, roomID uuid.UUID) bool {
+	var archived int64
+	if err := h.DB.Model(&models.Room{}).Where("id = ? AND archived_at IS NOT NULL", roomID).Count(&archived).Error; err != nil {
+		http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		return false
+	}
+	if archived > 0 {
+		http.Error(w, "ROOM_ARCHIVED", http.StatusConflict)
+		return false
+	}
+	return true
+}
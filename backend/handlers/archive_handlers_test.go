@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveRoom_PendingItems(t *testing.T) {
+	h := &Handler{DB: memberDB(t, RoleAdmin, "status IN")}
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), "userID", uuid.New()))
+	w := httptest.NewRecorder()
+	h.ArchiveRoom(w, r, httprouter.Params{{Key: "roomID", Value: uuid.New().String()}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "PENDING_ITEMS\n", w.Body.String())
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/storage"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxAttachmentSize      = 10 << 20
+	MaxAttachmentsPerGroup = 10
+)
+
+var allowedAttachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"application/pdf": true,
+}
+
+func (h *Handler) UploadAttachment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+	groupID, err := uuid.Parse(ps.ByName("groupID"))
+	if err != nil {
+		http.Error(w, "INVALID_GROUP_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermAddItems); !ok {
+		return
+	}
+
+	var itemCount int64
+	if err := h.DB.Model(&models.Item{}).Where("room_id = ? AND group_id = ?", roomID, groupID).Count(&itemCount).Error; err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+	if itemCount == 0 {
+		http.Error(w, "GROUPID_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+
+	var attachmentCount int64
+	if err := h.DB.Model(&models.Attachment{}).Where("group_id = ?", groupID).Count(&attachmentCount).Error; err != nil {
+		http.Error(w, "DB_ERROR_ATTACHMENTS", http.StatusInternalServerError)
+		return
+	}
+	if attachmentCount >= MaxAttachmentsPerGroup {
+		http.Error(w, "TOO_MANY_ATTACHMENTS", http.StatusBadRequest)
+		return
+	}
+
+	// leave some headroom for the multipart envelope
+	r.Body = http.MaxBytesReader(w, r.Body, MaxAttachmentSize+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "ATTACHMENT_TOO_LARGE", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		}
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxAttachmentSize+1))
+	if err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+	if len(data) > MaxAttachmentSize {
+		http.Error(w, "ATTACHMENT_TOO_LARGE", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, "EMPTY_ATTACHMENT", http.StatusBadRequest)
+		return
+	}
+
+	// trust the content, not the client-supplied header
+	contentType := http.DetectContentType(data)
+	if !allowedAttachmentTypes[contentType] {
+		http.Error(w, "UNSUPPORTED_ATTACHMENT_TYPE", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	attachment := models.Attachment{
+		RoomID:      roomID,
+		GroupID:     groupID,
+		UploaderID:  userID,
+		FileName:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		StorageKey:  fmt.Sprintf("%s/%s", roomID, uuid.New()),
+	}
+
+	if _, err := h.Blobs.Put(attachment.StorageKey, bytes.NewReader(data)); err != nil {
+		http.Error(w, "BLOB_STORE_ERROR", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.HasPrefix(contentType, "image/") {
+		if thumb, err := storage.MakeThumbnail(bytes.NewReader(data), storage.ThumbnailMaxDim); err == nil {
+			thumbKey := attachment.StorageKey + "_thumb"
+			if _, err := h.Blobs.Put(thumbKey, bytes.NewReader(thumb)); err == nil {
+				attachment.ThumbnailKey = thumbKey
+				attachment.HasThumbnail = true
+			}
+		}
+	}
+
+	if err := h.DB.Create(&attachment).Error; err != nil {
+		h.deleteAttachmentBlobs(attachment)
+		http.Error(w, "DB_ERROR_ATTACHMENTS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachment)
+}
+
+func (h *Handler) GetAttachments(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if !h.requireRoomMember(w, roomID, userID) {
+		return
+	}
+
+	attachments := []models.Attachment{}
+	if err := h.DB.Where("room_id = ? AND group_id = ?", roomID, ps.ByName("groupID")).
+		Order("created_at ASC").Find(&attachments).Error; err != nil {
+		http.Error(w, "DB_ERROR_ATTACHMENTS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachments)
+}
+
+func (h *Handler) DownloadAttachment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.serveAttachment(w, r, ps, false)
+}
+
+func (h *Handler) GetAttachmentThumbnail(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.serveAttachment(w, r, ps, true)
+}
+
+func (h *Handler) DeleteAttachment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	attachment, roomUser, ok := h.loadAttachment(w, r, ps)
+	if !ok {
+		return
+	}
+	if attachment.UploaderID != roomUser.UserID && !roleCan(roomUser.Role, PermEditAnyItems) {
+		http.Error(w, "PERMISSION_DENIED", http.StatusForbidden)
+		return
+	}
+	if !h.requireRoomOpen(w, attachment.RoomID) {
+		return
+	}
+
+	if err := h.DB.Delete(&models.Attachment{}, "id = ?", attachment.ID).Error; err != nil {
+		http.Error(w, "DB_ERROR_ATTACHMENTS", http.StatusInternalServerError)
+		return
+	}
+	h.deleteAttachmentBlobs(attachment)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "ATTACHMENT_DELETED"})
+}
+
+func (h *Handler) serveAttachment(w http.ResponseWriter, r *http.Request, ps httprouter.Params, thumbnail bool) {
+	attachment, _, ok := h.loadAttachment(w, r, ps)
+	if !ok {
+		return
+	}
+
+	key, contentType := attachment.StorageKey, attachment.ContentType
+	if thumbnail {
+		if !attachment.HasThumbnail {
+			http.Error(w, "THUMBNAIL_NOT_FOUND", http.StatusNotFound)
+			return
+		}
+		key, contentType = attachment.ThumbnailKey, "image/jpeg"
+	}
+
+	blob, err := h.Blobs.Get(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "ATTACHMENT_NOT_FOUND", http.StatusNotFound)
+		} else {
+			http.Error(w, "BLOB_STORE_ERROR", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer blob.Close()
+
+	disposition := "inline"
+	if !thumbnail && r.URL.Query().Get("download") == "1" {
+		disposition = "attachment"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%s", disposition, strconv.Quote(attachment.FileName)))
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, blob)
+}
+
+func (h *Handler) loadAttachment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) (models.Attachment, models.RoomUser, bool) {
+	var attachment models.Attachment
+
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return attachment, models.RoomUser{}, false
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	roomUser, ok := h.requireRoomPermission(w, roomID, userID, PermViewRoom)
+	if !ok {
+		return attachment, roomUser, false
+	}
+
+	if err := h.DB.Where("id = ? AND room_id = ?", ps.ByName("attachmentID"), roomID).First(&attachment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "ATTACHMENT_NOT_FOUND", http.StatusNotFound)
+		} else {
+			http.Error(w, "DB_ERROR_ATTACHMENTS", http.StatusInternalServerError)
+		}
+		return attachment, roomUser, false
+	}
+	return attachment, roomUser, true
+}
+
+// deleteOrphanedAttachments removes the attachments of every group in groupIDs
+// that no longer has any items left, mirroring the hard delete of items.
+func (h *Handler) deleteOrphanedAttachments(roomID uuid.UUID, groupIDs ...uuid.UUID) {
+	for _, groupID := range groupIDs {
+		var remaining int64
+		if err := h.DB.Model(&models.Item{}).Where("room_id = ? AND group_id = ?", roomID, groupID).Count(&remaining).Error; err != nil || remaining > 0 {
+			continue
+		}
+
+		var attachments []models.Attachment
+		if err := h.DB.Where("room_id = ? AND group_id = ?", roomID, groupID).Find(&attachments).Error; err != nil {
+			continue
+		}
+		if err := h.DB.Delete(&models.Attachment{}, "room_id = ? AND group_id = ?", roomID, groupID).Error; err != nil {
+			continue
+		}
+		for _, attachment := range attachments {
+			h.deleteAttachmentBlobs(attachment)
+		}
+	}
+}
+
+func (h *Handler) deleteAttachmentBlobs(attachment models.Attachment) {
+	if err := h.Blobs.Delete(attachment.StorageKey); err != nil {
+		log.Printf("failed to delete blob %s: %v", attachment.StorageKey, err)
+	}
+	if attachment.ThumbnailKey != "" {
+		if err := h.Blobs.Delete(attachment.ThumbnailKey); err != nil {
+			log.Printf("failed to delete blob %s: %v", attachment.ThumbnailKey, err)
+		}
+	}
+}
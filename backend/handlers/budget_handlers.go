@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/stats"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const (
+	BudgetPeriodTotal   string = "TOTAL"
+	BudgetPeriodWeekly  string = "WEEKLY"
+	BudgetPeriodMonthly string = "MONTHLY"
+)
+
+const BudgetAlertEvent = "budget_alert"
+
+// budgetThresholds are the percentages of a budget that raise an alert.
+var budgetThresholds = []int{80, 100}
+
+type CreateBudgetRequest struct {
+	Name     string     `json:"name"`
+	Category string     `json:"category"`
+	UserID   *uuid.UUID `json:"user_id"`
+	Period   string     `json:"period"`
+	Amount   int64      `json:"amount"`
+}
+
+type BudgetStatus struct {
+	Budget      models.Budget `json:"budget"`
+	PeriodStart *time.Time    `json:"period_start"`
+	PeriodEnd   *time.Time    `json:"period_end"`
+	Spent       int64         `json:"spent"`
+	Remaining   int64         `json:"remaining"`
+	PercentUsed float64       `json:"percent_used"`
+}
+
+func (h *Handler) CreateBudget(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermChangeSettings); !ok {
+		return
+	}
+
+	var req CreateBudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if len(req.Name) == 0 || len(req.Name) > 50 {
+		http.Error(w, "INVALID_NAME_LENGTH", http.StatusBadRequest)
+		return
+	}
+	if req.Amount <= 0 {
+		http.Error(w, "INVALID_AMOUNT", http.StatusBadRequest)
+		return
+	}
+
+	req.Period = strings.ToUpper(req.Period)
+	if req.Period == "" {
+		req.Period = BudgetPeriodTotal
+	}
+	if req.Period != BudgetPeriodTotal && req.Period != BudgetPeriodWeekly && req.Period != BudgetPeriodMonthly {
+		http.Error(w, "INVALID_PERIOD", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID != nil {
+		var count int64
+		if err := h.DB.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", roomID, *req.UserID).Count(&count).Error; err != nil {
+			http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+			return
+		}
+		if count == 0 {
+			http.Error(w, "USER_NOT_IN_ROOM", http.StatusBadRequest)
+			return
+		}
+	}
+
+	budget := models.Budget{
+		RoomID:    roomID,
+		Name:      req.Name,
+		Category:  strings.TrimSpace(req.Category),
+		UserID:    req.UserID,
+		Period:    req.Period,
+		Amount:    req.Amount,
+		CreatorID: userID,
+	}
+	if err := h.DB.Create(&budget).Error; err != nil {
+		http.Error(w, "DB_ERROR_BUDGETS", http.StatusInternalServerError)
+		return
+	}
+	h.recordActivity(roomID, userID, ActivitySettingsChanged, nil, map[string]interface{}{"budget": budget})
+
+	status, err := h.budgetStatus(budget, time.Now())
+	if err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// GetBudgets reports every budget in the room with its spending in the
+// current period, or the period containing ?at= if given.
+func (h *Handler) GetBudgets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if !h.requireRoomMember(w, roomID, userID) {
+		return
+	}
+
+	at := time.Now()
+	if s := r.URL.Query().Get("at"); s != "" {
+		if at, _, err = parseDateParam(s); err != nil {
+			http.Error(w, "INVALID_DATE", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var budgets []models.Budget
+	if err := h.DB.Where("room_id = ?", roomID).Order("created_at ASC").Find(&budgets).Error; err != nil {
+		http.Error(w, "DB_ERROR_BUDGETS", http.StatusInternalServerError)
+		return
+	}
+
+	statuses := []BudgetStatus{}
+	for _, budget := range budgets {
+		status, err := h.budgetStatus(budget, at)
+		if err != nil {
+			http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+			return
+		}
+		statuses = append(statuses, *status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+func (h *Handler) DeleteBudget(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermChangeSettings); !ok {
+		return
+	}
+
+	budgetID := ps.ByName("budgetID")
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Budget{}, "id = ? AND room_id = ?", budgetID, roomID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Delete(&models.BudgetAlert{}, "budget_id = ?", budgetID).Error
+	}); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "BUDGET_NOT_FOUND", http.StatusNotFound)
+		} else {
+			http.Error(w, "DB_ERROR_BUDGETS", http.StatusInternalServerError)
+		}
+		return
+	}
+	h.recordActivity(roomID, userID, ActivitySettingsChanged, map[string]interface{}{"budget_id": budgetID}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "BUDGET_DELETED"})
+}
+
+func (h *Handler) GetBudgetAlerts(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if !h.requireRoomMember(w, roomID, userID) {
+		return
+	}
+
+	alerts := []models.BudgetAlert{}
+	if err := h.DB.Where("room_id = ?", roomID).Order("created_at DESC").Limit(100).Find(&alerts).Error; err != nil {
+		http.Error(w, "DB_ERROR_BUDGET_ALERTS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+// checkBudgets re-evaluates the room's budgets for the periods touched by
+// items, persists an alert for every threshold crossed for the first time in
+// a period and pushes those alerts to everyone in the room.
+func (h *Handler) checkBudgets(roomID uuid.UUID, items []models.Item) {
+	var budgets []models.Budget
+	if err := h.DB.Where("room_id = ?", roomID).Find(&budgets).Error; err != nil {
+		log.Printf("failed to load budgets for room %s: %v", roomID, err)
+		return
+	}
+
+	newAlerts := []models.BudgetAlert{}
+	for _, budget := range budgets {
+		periods := map[time.Time]time.Time{}
+		for _, item := range items {
+			if budgetCovers(budget, item) {
+				start, end := budgetPeriod(budget.Period, item.OccurredAt)
+				periods[start] = end
+			}
+		}
+
+		for start, end := range periods {
+			spent, err := h.budgetSpent(budget, start, end)
+			if err != nil {
+				log.Printf("failed to compute spending for budget %s: %v", budget.ID, err)
+				continue
+			}
+
+			for _, threshold := range budgetThresholds {
+				if spent*100 < budget.Amount*int64(threshold) {
+					continue
+				}
+				alert := models.BudgetAlert{
+					RoomID:      roomID,
+					BudgetID:    budget.ID,
+					Threshold:   threshold,
+					PeriodStart: start,
+					Spent:       spent,
+					Budgeted:    budget.Amount,
+				}
+				result := h.DB.Where("budget_id = ? AND threshold = ? AND period_start = ?", budget.ID, threshold, start).
+					FirstOrCreate(&alert)
+				if result.Error != nil {
+					log.Printf("failed to store budget alert for budget %s: %v", budget.ID, result.Error)
+					continue
+				}
+				if result.RowsAffected > 0 {
+					newAlerts = append(newAlerts, alert)
+				}
+			}
+		}
+	}
+
+	if len(newAlerts) > 0 {
+		h.pushUpdatesToAllClients(roomID.String(), &SSEUpdateInfo{
+			Event:        BudgetAlertEvent,
+			BudgetAlerts: newAlerts,
+		})
+	}
+}
+
+func (h *Handler) budgetStatus(budget models.Budget, at time.Time) (*BudgetStatus, error) {
+	start, end := budgetPeriod(budget.Period, at)
+	spent, err := h.budgetSpent(budget, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	status := BudgetStatus{
+		Budget:      budget,
+		Spent:       spent,
+		Remaining:   budget.Amount - spent,
+		PercentUsed: float64(spent) * 100 / float64(budget.Amount),
+	}
+	if budget.Period != BudgetPeriodTotal {
+		status.PeriodStart = &start
+		status.PeriodEnd = &end
+	}
+	return &status, nil
+}
+
+// budgetSpent sums the approved expenses counted against budget in [start, end).
+// Member budgets count what the member consumed, not what they paid for.
+func (h *Handler) budgetSpent(budget models.Budget, start time.Time, end time.Time) (int64, error) {
+	query := h.DB.Model(&models.Item{}).
+		Where("room_id = ? AND status = ? AND transaction_type = ?", budget.RoomID, ItemApproved, Expense).
+		Where("occurred_at >= ? AND occurred_at < ?", start, end)
+	if budget.Category != "" {
+		query = query.Where("LOWER(category) = LOWER(?)", budget.Category)
+	}
+	if budget.UserID != nil {
+		query = query.Where("from_user_id = ?", *budget.UserID)
+	}
+
+	var spent int64
+	err := query.Select("COALESCE(SUM(amount), 0)").Scan(&spent).Error
+	return spent, err
+}
+
+func budgetCovers(budget models.Budget, item models.Item) bool {
+	if item.TransactionType != Expense || item.Status != ItemApproved {
+		return false
+	}
+	if budget.Category != "" && !strings.EqualFold(budget.Category, item.Category) {
+		return false
+	}
+	if budget.UserID != nil && *budget.UserID != item.FromUserID {
+		return false
+	}
+	return true
+}
+
+// budgetPeriod returns the UTC period [start, end) of the given kind that
+// contains t. Weeks start on Monday.
+func budgetPeriod(period string, t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+
+	switch period {
+	case BudgetPeriodWeekly:
+		start := stats.BucketStart(stats.Weekly, t)
+		return start, start.AddDate(0, 0, 7)
+	case BudgetPeriodMonthly:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	return time.Unix(0, 0).UTC(), time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+// CloneRoomRequest names the copy. With Template set the copy is kept as a
+// template, to be cloned again later instead of used.
+type CloneRoomRequest struct {
+	RoomName string `json:"roomName"`
+	Template bool   `json:"template"`
+}
+
+// CloneRoom copies a room or template into a new room: its settings,
+// placeholders, households, budgets and exchange rates. The item history is
+// left behind, and the caller is the only real member of the copy, as its
+// admin; others join it through invites as they would any room.
+func (h *Handler) CloneRoom(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	source, ok := h.loadRoomWithPermission(w, r, ps, PermCloneRoom)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value("userID").(uuid.UUID)
+
+	var req CloneRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_REQUEST", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.RoomName)
+	if len(name) < 5 || len(name) > 20 {
+		http.Error(w, "INVALID_NAME_LENGTH", http.StatusBadRequest)
+		return
+	}
+
+	joinCode, err := models.NewUniqueJoinCode(h.DB)
+	if err != nil {
+		http.Error(w, "JOIN_CODE_ERROR", http.StatusInternalServerError)
+		return
+	}
+	room := models.Room{
+		Name:                name,
+		JoinCode:            &joinCode,
+		BaseCurrency:        source.BaseCurrency,
+		ForeignCurrencies:   source.ForeignCurrencies,
+		FxMode:              source.FxMode,
+		SimplifyAlgo:        source.SimplifyAlgo,
+		RequireItemApproval: source.RequireItemApproval,
+		RequireJoinApproval: source.RequireJoinApproval,
+		IsTemplate:          req.Template,
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&room).Error; err != nil {
+			return err
+		}
+		return cloneRoomContents(tx, source.ID, room.ID, userID)
+	}); err != nil {
+		http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordActivity(room.ID, userID, ActivityRoomCloned, nil, map[string]interface{}{
+		"source_room_id": source.ID,
+		"template":       room.IsTemplate,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room)
+}
+
+// cloneRoomContents copies everything of sourceID but its items and real
+// members into roomID. Placeholders are recreated for the new room, so
+// references to them are mapped to their copies.
+func cloneRoomContents(tx *gorm.DB, sourceID uuid.UUID, roomID uuid.UUID, userID uuid.UUID) error {
+	var roomUsers []models.RoomUser
+	if err := tx.Where("room_id = ? AND status = ?", sourceID, MemberIn).Find(&roomUsers).Error; err != nil {
+		return err
+	}
+	var placeholders []models.User
+	if err := tx.Where("placeholder_room_id = ?", sourceID).Find(&placeholders).Error; err != nil {
+		return err
+	}
+
+	copyOf := map[uuid.UUID]uuid.UUID{}
+	for _, placeholder := range placeholders {
+		clone := models.User{Name: placeholder.Name, PlaceholderRoomID: &roomID}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		copyOf[placeholder.ID] = clone.ID
+	}
+	copyOf[userID] = userID
+
+	var households []models.Household
+	if err := tx.Where("room_id = ?", sourceID).Find(&households).Error; err != nil {
+		return err
+	}
+	householdCopyOf := map[uuid.UUID]uuid.UUID{}
+	for _, household := range households {
+		clone := models.Household{RoomID: roomID, Name: household.Name}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		householdCopyOf[household.ID] = clone.ID
+	}
+
+	// the caller always administers the copy, even if not a source member;
+	// other real members are not enrolled without their consent
+	members := []models.RoomUser{{RoomID: roomID, UserID: userID, Status: MemberIn, Role: RoleAdmin}}
+	for _, roomUser := range roomUsers {
+		clone, ok := copyOf[roomUser.UserID]
+		if !ok {
+			continue
+		}
+		member := models.RoomUser{RoomID: roomID, UserID: clone, Status: MemberIn, Role: roomUser.Role}
+		if roomUser.HouseholdID != nil {
+			householdID := householdCopyOf[*roomUser.HouseholdID]
+			member.HouseholdID = &householdID
+		}
+		if roomUser.UserID == userID {
+			members[0].HouseholdID = member.HouseholdID
+			continue
+		}
+		members = append(members, member)
+	}
+	if err := tx.Create(&members).Error; err != nil {
+		return err
+	}
+
+	var budgets []models.Budget
+	if err := tx.Where("room_id = ?", sourceID).Find(&budgets).Error; err != nil {
+		return err
+	}
+	for _, budget := range budgets {
+		clone := models.Budget{
+			RoomID:    roomID,
+			Name:      budget.Name,
+			Category:  budget.Category,
+			Period:    budget.Period,
+			Amount:    budget.Amount,
+			CreatorID: userID,
+		}
+		if budget.UserID != nil {
+			// budgets of members left behind go with them
+			budgetUserID, ok := copyOf[*budget.UserID]
+			if !ok {
+				continue
+			}
+			clone.UserID = &budgetUserID
+		}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+	}
+
+	var rates []models.ExchangeRate
+	if err := tx.Where("room_id = ?", sourceID).Find(&rates).Error; err != nil {
+		return err
+	}
+	for _, rate := range rates {
+		clone := models.ExchangeRate{
+			RoomID:       roomID,
+			Currency:     rate.Currency,
+			BaseCurrency: rate.BaseCurrency,
+			Rate:         rate.Rate,
+			EffectiveAt:  rate.EffectiveAt,
+			CreatorID:    userID,
+		}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requireNotTemplate writes an error response and returns false if roomID is
+// a template.
+func (h *Handler) requireNotTemplate(w http.ResponseWriter, roomID uuid.UUID) bool {
+	var templates int64
+	if err := h.DB.Model(&models.Room{}).Where("id = ? AND is_template", roomID).Count(&templates).Error; err != nil {
+		http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		return false
+	}
+	if templates > 0 {
+		http.Error(w, "ROOM_IS_TEMPLATE", http.StatusConflict)
+		return false
+	}
+	return true
+}
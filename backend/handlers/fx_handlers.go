@@ -1 +1,419 @@
 package handlers
+
+import (
+	"backend/fx"
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const MaxForeignCurrencies = 20
+
+type UpdateCurrenciesRequest struct {
+	// BaseCurrency can only be given while the room has none yet.
+	BaseCurrency      string   `json:"base_currency"`
+	ForeignCurrencies []string `json:"foreign_currencies"`
+	// FxMode is LOCKED or FLOATING; empty keeps the current mode.
+	FxMode string `json:"fx_mode"`
+}
+
+// DisplaySimplifiedItem is a stored settlement with its amount also shown in
+// the display currency the client asked for.
+type DisplaySimplifiedItem struct {
+	models.SimplifiedItem
+	DisplayAmount int64 `json:"display_amount"`
+}
+
+type CreateExchangeRateRequest struct {
+	Currency string `json:"currency"`
+	// Rate is the number of base currency units bought by one unit of
+	// Currency, as a decimal string.
+	Rate        string     `json:"rate"`
+	EffectiveAt *time.Time `json:"effective_at"`
+}
+
+func (h *Handler) GetCurrencies(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomForMember(w, r, ps)
+	if !ok {
+		return
+	}
+
+	response := map[string]interface{}{
+		"baseCurrency":      room.BaseCurrency,
+		"foreignCurrencies": room.ForeignCurrencies,
+		"fxMode":            room.FxMode,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *Handler) UpdateCurrencies(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermChangeSettings)
+	if !ok {
+		return
+	}
+
+	var req UpdateCurrenciesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+
+	settings := newRoomSettingsChange(&room, r.Context().Value("userID").(uuid.UUID))
+	baseCurrency := room.BaseCurrency
+	if req.BaseCurrency != "" {
+		baseCurrency = strings.ToUpper(strings.TrimSpace(req.BaseCurrency))
+		if err := models.ValidateCurrency(baseCurrency); err != nil {
+			http.Error(w, "INVALID_CURRENCY", http.StatusBadRequest)
+			return
+		}
+		if room.BaseCurrency != "" && room.BaseCurrency != baseCurrency {
+			http.Error(w, "BASE_CURRENCY_ALREADY_SET", http.StatusConflict)
+			return
+		}
+		settings.set("base_currency", room.BaseCurrency, baseCurrency, baseCurrency)
+	}
+
+	currencies := models.CurrencyList{}
+	for _, code := range req.ForeignCurrencies {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if err := models.ValidateCurrency(code); err != nil {
+			http.Error(w, "INVALID_CURRENCY", http.StatusBadRequest)
+			return
+		}
+		if code != baseCurrency && !currencies.Contains(code) {
+			currencies = append(currencies, code)
+		}
+	}
+	if len(currencies) > MaxForeignCurrencies {
+		http.Error(w, "TOO_MANY_CURRENCIES", http.StatusBadRequest)
+		return
+	}
+	settings.set("foreign_currencies", strings.Join(room.ForeignCurrencies, ","), strings.Join(currencies, ","), currencies)
+
+	if req.FxMode != "" {
+		fxMode := strings.ToUpper(req.FxMode)
+		if fxMode != FxModeLocked && fxMode != FxModeFloating {
+			http.Error(w, "INVALID_FX_MODE", http.StatusBadRequest)
+			return
+		}
+		settings.set("fx_mode", room.FxMode, fxMode, fxMode)
+	}
+
+	if err := h.saveRoomSettings(settings); err != nil {
+		http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"baseCurrency":      room.BaseCurrency,
+		"foreignCurrencies": room.ForeignCurrencies,
+		"fxMode":            room.FxMode,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetExchangeRates lists the recorded rates of the room, newest first,
+// optionally only those of ?currency=.
+func (h *Handler) GetExchangeRates(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomForMember(w, r, ps)
+	if !ok {
+		return
+	}
+
+	query := h.DB.Where("room_id = ?", room.ID)
+	if currency := strings.ToUpper(r.URL.Query().Get("currency")); currency != "" {
+		query = query.Where("currency = ?", currency)
+	}
+
+	rates := []models.ExchangeRate{}
+	if err := query.Order("effective_at DESC").Find(&rates).Error; err != nil {
+		http.Error(w, "DB_ERROR_EXCHANGE_RATES", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
+
+func (h *Handler) CreateExchangeRate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermChangeSettings)
+	if !ok {
+		return
+	}
+
+	var req CreateExchangeRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+
+	if room.BaseCurrency == "" {
+		http.Error(w, "BASE_CURRENCY_NOT_SET", http.StatusBadRequest)
+		return
+	}
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if !room.ForeignCurrencies.Contains(currency) {
+		http.Error(w, "CURRENCY_NOT_IN_ROOM", http.StatusBadRequest)
+		return
+	}
+	rate, err := models.ParseRate(req.Rate)
+	if err != nil {
+		http.Error(w, "INVALID_RATE", http.StatusBadRequest)
+		return
+	}
+
+	exchangeRate := models.ExchangeRate{
+		RoomID:       room.ID,
+		Currency:     currency,
+		BaseCurrency: room.BaseCurrency,
+		Rate:         rate,
+		EffectiveAt:  time.Now(),
+		CreatorID:    r.Context().Value("userID").(uuid.UUID),
+	}
+	if req.EffectiveAt != nil {
+		exchangeRate.EffectiveAt = *req.EffectiveAt
+	}
+
+	if err := h.DB.Create(&exchangeRate).Error; err != nil {
+		http.Error(w, "DB_ERROR_EXCHANGE_RATES", http.StatusInternalServerError)
+		return
+	}
+	h.invalidateRates(room.ID)
+	h.recordActivity(room.ID, exchangeRate.CreatorID, ActivitySettingsChanged, nil, map[string]interface{}{"exchange_rate": exchangeRate})
+	if room.FxMode == FxModeFloating {
+		h.resimplifyForRates(room.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exchangeRate)
+}
+
+// GetExchangeRateQuote reports the rate the room would use to convert ?from=
+// into ?to= (default: the base currency) on ?date= (default: now).
+func (h *Handler) GetExchangeRateQuote(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomForMember(w, r, ps)
+	if !ok {
+		return
+	}
+
+	q := fx.Query{
+		RoomID: room.ID,
+		From:   strings.ToUpper(r.URL.Query().Get("from")),
+		To:     strings.ToUpper(r.URL.Query().Get("to")),
+		Date:   time.Now(),
+	}
+	if q.To == "" {
+		q.To = room.BaseCurrency
+	}
+	if models.ValidateCurrency(q.From) != nil || models.ValidateCurrency(q.To) != nil {
+		http.Error(w, "INVALID_CURRENCY", http.StatusBadRequest)
+		return
+	}
+	if s := r.URL.Query().Get("date"); s != "" {
+		date, dateOnly, err := parseDateParam(s)
+		if err != nil {
+			http.Error(w, "INVALID_DATE", http.StatusBadRequest)
+			return
+		}
+		// a bare date means any rate published during that day
+		if dateOnly {
+			date = date.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		q.Date = date
+	}
+
+	quote, err := h.Rates.Rate(q)
+	if err != nil {
+		writeError(w, rateError(err), "FX_PROVIDER_ERROR")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quote)
+}
+
+func (h *Handler) DeleteExchangeRate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermChangeSettings)
+	if !ok {
+		return
+	}
+
+	var exchangeRate models.ExchangeRate
+	if err := h.DB.First(&exchangeRate, "id = ? AND room_id = ?", ps.ByName("rateID"), room.ID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "EXCHANGE_RATE_NOT_FOUND", http.StatusNotFound)
+		} else {
+			http.Error(w, "DB_ERROR_EXCHANGE_RATES", http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := h.DB.Delete(&exchangeRate).Error; err != nil {
+		http.Error(w, "DB_ERROR_EXCHANGE_RATES", http.StatusInternalServerError)
+		return
+	}
+	h.invalidateRates(room.ID)
+	h.recordActivity(room.ID, r.Context().Value("userID").(uuid.UUID), ActivitySettingsChanged, map[string]interface{}{"exchange_rate": exchangeRate}, nil)
+	if room.FxMode == FxModeFloating {
+		h.resimplifyForRates(room.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "EXCHANGE_RATE_DELETED"})
+}
+
+// convertForeignItems fills in Amount, in the room's base currency, for every
+// item carrying a foreign amount, using the historical rate for the day the
+// item occurred. The rate used is recorded on the item.
+func (h *Handler) convertForeignItems(room *models.Room, items []models.Item) error {
+	for i := range items {
+		item := &items[i]
+		if item.ForeignCurrency == "" {
+			item.FxRate = ""
+			continue
+		}
+
+		if room.BaseCurrency == "" {
+			return &requestError{code: "BASE_CURRENCY_NOT_SET", status: http.StatusBadRequest}
+		}
+		if item.ForeignAmount <= 0 {
+			return &requestError{code: "INVALID_FOREIGN_AMOUNT", status: http.StatusBadRequest}
+		}
+
+		if item.ForeignCurrency == room.BaseCurrency {
+			item.Amount = item.ForeignAmount
+			item.FxRate = "1"
+			continue
+		}
+		if !room.ForeignCurrencies.Contains(item.ForeignCurrency) {
+			return &requestError{code: "CURRENCY_NOT_IN_ROOM", status: http.StatusBadRequest}
+		}
+
+		quote, err := h.Rates.Rate(fx.Query{
+			RoomID: room.ID,
+			From:   item.ForeignCurrency,
+			To:     room.BaseCurrency,
+			Date:   item.OccurredAt,
+		})
+		if err != nil {
+			return rateError(err)
+		}
+
+		foreign := models.Money{Amount: item.ForeignAmount, Currency: item.ForeignCurrency}
+		converted, err := foreign.Convert(quote.Rate, room.BaseCurrency)
+		if err != nil {
+			return &requestError{code: "FX_CONVERSION_FAILED", status: http.StatusBadRequest}
+		}
+		item.Amount = converted.Amount
+		item.FxRate = quote.Rate
+	}
+	return nil
+}
+
+// displayConversion resolves ?display_currency= into the current rate from the
+// room's base currency, or nil if no display currency was asked for.
+func (h *Handler) displayConversion(room *models.Room, r *http.Request) (*fx.Quote, error) {
+	currency := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("display_currency")))
+	if currency == "" {
+		return nil, nil
+	}
+	if err := models.ValidateCurrency(currency); err != nil {
+		return nil, &requestError{code: "INVALID_CURRENCY", status: http.StatusBadRequest}
+	}
+	if room.BaseCurrency == "" {
+		return nil, &requestError{code: "BASE_CURRENCY_NOT_SET", status: http.StatusBadRequest}
+	}
+
+	quote, err := h.Rates.Rate(fx.Query{
+		RoomID: room.ID,
+		From:   room.BaseCurrency,
+		To:     currency,
+		Date:   time.Now(),
+	})
+	if err != nil {
+		return nil, rateError(err)
+	}
+	return &quote, nil
+}
+
+func displayAmount(amount int64, quote *fx.Quote) (int64, error) {
+	converted, err := models.Money{Amount: amount, Currency: quote.From}.Convert(quote.Rate, quote.To)
+	if err != nil {
+		return 0, &requestError{code: "FX_CONVERSION_FAILED", status: http.StatusBadRequest}
+	}
+	return converted.Amount, nil
+}
+
+// displaySimplifiedItems converts copies of the stored settlements, which stay
+// in the base currency.
+func displaySimplifiedItems(simplifiedItems []models.SimplifiedItem, quote *fx.Quote) ([]DisplaySimplifiedItem, error) {
+	res := make([]DisplaySimplifiedItem, 0, len(simplifiedItems))
+	for _, item := range simplifiedItems {
+		amount, err := displayAmount(item.Amount, quote)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, DisplaySimplifiedItem{SimplifiedItem: item, DisplayAmount: amount})
+	}
+	return res, nil
+}
+
+func displayBalances(balances map[uuid.UUID]int64, quote *fx.Quote) (map[uuid.UUID]int64, error) {
+	res := map[uuid.UUID]int64{}
+	for userID, balance := range balances {
+		amount, err := displayAmount(balance, quote)
+		if err != nil {
+			return nil, err
+		}
+		res[userID] = amount
+	}
+	return res, nil
+}
+
+// rateError maps a missing rate to a client error; provider failures are
+// passed through as internal errors.
+func rateError(err error) error {
+	if errors.Is(err, fx.ErrRateNotFound) {
+		return &requestError{code: "NO_FX_RATE", status: http.StatusBadRequest}
+	}
+	return err
+}
+
+func (h *Handler) invalidateRates(roomID uuid.UUID) {
+	if invalidator, ok := h.Rates.(fx.Invalidator); ok {
+		invalidator.Invalidate(roomID)
+	}
+}
+
+func (h *Handler) loadRoomForMember(w http.ResponseWriter, r *http.Request, ps httprouter.Params) (models.Room, bool) {
+	return h.loadRoomWithPermission(w, r, ps, PermViewRoom)
+}
+
+func (h *Handler) loadRoomWithPermission(w http.ResponseWriter, r *http.Request, ps httprouter.Params, perm Permission) (models.Room, bool) {
+	var room models.Room
+
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return room, false
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, perm); !ok {
+		return room, false
+	}
+
+	if err := h.DB.First(&room, "id = ?", roomID).Error; err != nil {
+		http.Error(w, "ROOM_NOT_FOUND", http.StatusNotFound)
+		return room, false
+	}
+	return room, true
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"backend/fx"
+	"backend/models"
+	"encoding/json"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// FxModeLocked keeps every foreign item at the rate of the day it occurred.
+	FxModeLocked = "LOCKED"
+	// FxModeFloating revalues foreign items at current rates until settled.
+	FxModeFloating = "FLOATING"
+)
+
+type MemberFxResult struct {
+	UserID          uuid.UUID `json:"user_id"`
+	LockedBalance   int64     `json:"locked_balance"`
+	FloatingBalance int64     `json:"floating_balance"`
+	// Gain is what floating rates add to the member's balance compared with
+	// locked rates; negative for a loss.
+	Gain int64 `json:"gain"`
+}
+
+// GetFxReport compares every member's balance at locked rates with their
+// balance at today's rates, whichever mode the room is in.
+func (h *Handler) GetFxReport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomForMember(w, r, ps)
+	if !ok {
+		return
+	}
+
+	var items []models.Item
+	if err := h.DB.Where("room_id = ? AND status = ?", room.ID, ItemApproved).Find(&items).Error; err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+
+	floating, quotes := h.floatItems(&room, items)
+	locked, err := balancesOf(items)
+	if err != nil {
+		http.Error(w, "BALANCE_OVERFLOW", http.StatusUnprocessableEntity)
+		return
+	}
+	current, err := balancesOf(floating)
+	if err != nil {
+		http.Error(w, "BALANCE_OVERFLOW", http.StatusUnprocessableEntity)
+		return
+	}
+
+	members := []MemberFxResult{}
+	for userID, balance := range locked {
+		members = append(members, MemberFxResult{
+			UserID:          userID,
+			LockedBalance:   balance,
+			FloatingBalance: current[userID],
+			Gain:            current[userID] - balance,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID.String() < members[j].UserID.String() })
+
+	response := map[string]interface{}{
+		"mode":     room.FxMode,
+		"currency": room.BaseCurrency,
+		"rates":    quotes,
+		"members":  members,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// resimplifyForRates recomputes the settlement plan after the rates it depends
+// on changed and pushes it to everyone in the room.
+func (h *Handler) resimplifyForRates(roomID uuid.UUID) {
+	simplifiedItems, err := h.resimplify(roomID)
+	if err != nil {
+		log.Printf("failed to simplify items for room %s: %v", roomID, err)
+		return
+	}
+	h.pushUpdatesToAllClients(roomID.String(), &SSEUpdateInfo{SimplifiedItems: simplifiedItems})
+}
+
+// floatItems returns copies of items with foreign amounts converted at
+// today's rate, along with the rates used. Only what is still owed floats:
+// between each pair of members, items up to the last time they were square
+// keep their locked amounts, and a settlement paying off part of a foreign
+// debt leaves only the rest of it at today's rate. Items whose rate a
+// settlement fixed, and items whose currency has no rate, keep their locked
+// amount.
+func (h *Handler) floatItems(room *models.Room, items []models.Item) ([]models.Item, map[string]fx.Quote) {
+	quotes := map[string]fx.Quote{}
+	missing := map[string]bool{}
+	now := time.Now()
+	rateOf := func(currency string) (fx.Quote, bool) {
+		if quote, ok := quotes[currency]; ok || missing[currency] {
+			return quote, ok
+		}
+		quote, err := h.Rates.Rate(fx.Query{RoomID: room.ID, From: currency, To: room.BaseCurrency, Date: now})
+		if err != nil {
+			log.Printf("no current %s rate for room %s, keeping locked amounts: %v", currency, room.ID, err)
+			missing[currency] = true
+			return fx.Quote{}, false
+		}
+		quotes[currency] = quote
+		return quote, true
+	}
+
+	res := make([]models.Item, len(items))
+	copy(res, items)
+
+	type memberPair struct{ a, b uuid.UUID }
+	pairs := map[memberPair][]int{}
+	for i, item := range res {
+		if item.FromUserID == item.ToUserID {
+			continue
+		}
+		pair := memberPair{item.ToUserID, item.FromUserID}
+		if pair.b.String() < pair.a.String() {
+			pair = memberPair{pair.b, pair.a}
+		}
+		pairs[pair] = append(pairs[pair], i)
+	}
+
+	for pair, indices := range pairs {
+		sort.SliceStable(indices, func(i, j int) bool { return res[indices[i]].OccurredAt.Before(res[indices[j]].OccurredAt) })
+		// what a owes b is negative, what b owes a positive
+		signed := func(item *models.Item) int64 {
+			if item.ToUserID == pair.a {
+				return item.Amount
+			}
+			return -item.Amount
+		}
+
+		var running int64
+		open := indices
+		for k, i := range indices {
+			if running += signed(&res[i]); running == 0 {
+				open = indices[k+1:]
+			}
+		}
+
+		var outstanding, foreign int64
+		floated := map[int]fx.Quote{}
+		converted := map[int]int64{}
+		for _, i := range open {
+			item := &res[i]
+			outstanding += signed(item)
+			if item.ForeignCurrency == "" || item.ForeignCurrency == room.BaseCurrency || item.FxLockedAt != nil {
+				continue
+			}
+			quote, ok := rateOf(item.ForeignCurrency)
+			if !ok {
+				continue
+			}
+			amount, err := models.Money{Amount: item.ForeignAmount, Currency: item.ForeignCurrency}.Convert(quote.Rate, room.BaseCurrency)
+			if err != nil {
+				continue
+			}
+			floated[i], converted[i] = quote, amount.Amount
+			foreign += signed(item)
+		}
+
+		// the share of the foreign debt not yet paid off by other items
+		owed, of := int64(1), int64(1)
+		switch {
+		case foreign == 0:
+		case outstanding == 0 || (outstanding > 0) != (foreign > 0):
+			owed = 0
+		case abs(outstanding) < abs(foreign):
+			owed, of = abs(outstanding), abs(foreign)
+		}
+		for i, quote := range floated {
+			item := &res[i]
+			item.Amount += mulDiv(converted[i]-item.Amount, owed, of)
+			if owed == of {
+				item.FxRate = quote.Rate
+			}
+		}
+	}
+	return res, quotes
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// mulDiv returns n*mul/div rounded toward zero, without overflowing on the way.
+func mulDiv(n int64, mul int64, div int64) int64 {
+	if mul == div {
+		return n
+	}
+	res := new(big.Int).Mul(big.NewInt(n), big.NewInt(mul))
+	return res.Quo(res, big.NewInt(div)).Int64()
+}
+
+// balancesOf computes the same net positions as roomBalances from items
+// already in memory. Sums that overflow give models.ErrMoneyOverflow.
+func balancesOf(items []models.Item) (map[uuid.UUID]int64, error) {
+	sums := map[uuid.UUID]models.Money{}
+	for _, item := range items {
+		if item.FromUserID == item.ToUserID {
+			continue
+		}
+		amount := models.Money{Amount: item.Amount}
+		credit, err := sums[item.ToUserID].Add(amount)
+		if err != nil {
+			return nil, err
+		}
+		debit, err := sums[item.FromUserID].Sub(amount)
+		if err != nil {
+			return nil, err
+		}
+		sums[item.ToUserID], sums[item.FromUserID] = credit, debit
+	}
+
+	balances := map[uuid.UUID]int64{}
+	for userID, sum := range sums {
+		balances[userID] = sum.Amount
+	}
+	return balances, nil
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"backend/fx"
+	"backend/models"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalancesOf(t *testing.T) {
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	balances, err := balancesOf([]models.Item{
+		{FromUserID: alice, ToUserID: bob, Amount: 500},
+		{FromUserID: bob, ToUserID: carol, Amount: 200},
+		{FromUserID: carol, ToUserID: carol, Amount: 999},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[uuid.UUID]int64{alice: -500, bob: 300, carol: 200}, balances)
+}
+
+func TestBalancesOf_Overflow(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	_, err := balancesOf([]models.Item{
+		{FromUserID: alice, ToUserID: bob, Amount: math.MaxInt64},
+		{FromUserID: alice, ToUserID: bob, Amount: 1},
+	})
+	assert.ErrorIs(t, err, models.ErrMoneyOverflow)
+}
+
+type fixedRates map[string]models.Rate
+
+func (r fixedRates) Rate(q fx.Query) (fx.Quote, error) {
+	rate, ok := r[q.From]
+	if !ok {
+		return fx.Quote{}, fx.ErrRateNotFound
+	}
+	return fx.Quote{From: q.From, To: q.To, Rate: rate, Date: q.Date}, nil
+}
+
+func TestFloatItems(t *testing.T) {
+	h := &Handler{Rates: fixedRates{"EUR": "1.2"}}
+	room := &models.Room{BaseCurrency: "USD"}
+	alice, bob := uuid.New(), uuid.New()
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	// alice owes bob 50 EUR, locked at 1.1
+	debt := models.Item{FromUserID: alice, ToUserID: bob, Amount: 5500, ForeignAmount: 5000, ForeignCurrency: "EUR", FxRate: "1.1", OccurredAt: day}
+	settle := func(amount int64, after time.Duration) models.Item {
+		return models.Item{FromUserID: bob, ToUserID: alice, Amount: amount, TransactionType: Transfer, OccurredAt: day.Add(after)}
+	}
+	balanceOf := func(items []models.Item) int64 {
+		floating, _ := h.floatItems(room, items)
+		balances, err := balancesOf(floating)
+		assert.NoError(t, err)
+		return balances[bob]
+	}
+
+	assert.Equal(t, int64(6000), balanceOf([]models.Item{debt}))
+
+	// a settled foreign debt stays settled whatever the rate does
+	assert.Equal(t, int64(0), balanceOf([]models.Item{debt, settle(5500, time.Hour)}))
+
+	// half paid off leaves 25 EUR floating
+	assert.Equal(t, int64(3000), balanceOf([]models.Item{debt, settle(2750, time.Hour)}))
+
+	// overpaid, nothing floats
+	assert.Equal(t, int64(-500), balanceOf([]models.Item{debt, settle(6000, time.Hour)}))
+
+	// only the debt after the pair was last square floats
+	later := debt
+	later.OccurredAt = day.Add(2 * time.Hour)
+	assert.Equal(t, int64(6000), balanceOf([]models.Item{later, debt, settle(5500, time.Hour)}))
+
+	// without a rate the locked amount stays
+	h.Rates = fixedRates{}
+	assert.Equal(t, int64(5500), balanceOf([]models.Item{debt}))
+}
+
+func TestFloatItems_LockedBySettlement(t *testing.T) {
+	h := &Handler{Rates: fixedRates{"EUR": "1.2"}}
+	room := &models.Room{BaseCurrency: "USD"}
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	items := []models.Item{
+		{FromUserID: alice, ToUserID: bob, Amount: 5500, ForeignAmount: 5000, ForeignCurrency: "EUR", FxRate: "1.1", OccurredAt: day},
+	}
+
+	// alice leaves handing her floated debt over to carol
+	floating, _ := h.floatItems(room, items)
+	locked := floating[0]
+	locked.FxLockedAt = &day
+	assert.Equal(t, int64(6000), locked.Amount)
+	items = []models.Item{locked, handOverItem(uuid.Nil, alice, carol, -6000)}
+
+	h.Rates = fixedRates{"EUR": "1.5"}
+	floating, _ = h.floatItems(room, items)
+	balances, err := balancesOf(floating)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), balances[alice])
+	assert.Equal(t, int64(6000), balances[bob])
+	assert.Equal(t, int64(-6000), balances[carol])
+}
@@ -2,10 +2,16 @@ package handlers
 
 import (
 	"backend/algorithm"
+	"backend/fx"
+	"backend/mail"
 	"backend/middleware"
 	"backend/models"
+	"backend/storage"
+	"errors"
+	"net/http"
 	"sync"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -15,11 +21,70 @@ type Handler struct {
 	Auth                  *middleware.Auth
 	RoomClients           *sync.Map
 	RoomToSimplifiedItems *sync.Map
+	// RoomToHouseholdItems caches the plans in which households settle as one
+	// party, alongside RoomToSimplifiedItems.
+	RoomToHouseholdItems *sync.Map
+	Blobs                storage.BlobStore
+	Rates                fx.RateProvider
+	Mailer               mail.Mailer
+	// PasswordResetURL is the page reset tokens are linked to in emails.
+	PasswordResetURL string
+	// ResetIPLimiter and ResetEmailLimiter limit password reset requests per
+	// client address and per email address.
+	ResetIPLimiter    *middleware.RateLimiter
+	ResetEmailLimiter *middleware.RateLimiter
+}
+
+// requestError is returned by helpers that know which error code and status
+// the handler should respond with.
+type requestError struct {
+	code   string
+	status int
+}
+
+func (e *requestError) Error() string {
+	return e.code
+}
+
+// writeError responds with the code of a requestError, or with fallback as an
+// internal server error for anything else.
+func writeError(w http.ResponseWriter, err error, fallback string) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		http.Error(w, reqErr.code, reqErr.status)
+		return
+	}
+	http.Error(w, fallback, http.StatusInternalServerError)
 }
 
 type SSEUpdateInfo struct {
+	// Event names a dedicated SSE event type; empty sends a plain message.
+	Event string `json:"-"`
+
 	NewItems        []models.Item           `json:"new_items"`
+	UpdatedItems    []models.Item           `json:"updated_items,omitempty"`
 	DeletedItems    []models.Item           `json:"deleted_items"`
 	SimplifiedItems []models.SimplifiedItem `json:"simplified_items"`
-	NewUser         *models.User            `json:"new_user"`
+	// HouseholdSimplifiedItems is filled in from the cache whenever
+	// SimplifiedItems is sent.
+	HouseholdSimplifiedItems []models.SimplifiedItem `json:"household_simplified_items,omitempty"`
+	NewUser                  *models.User            `json:"new_user"`
+	Approval                 *ApprovalEvent          `json:"approval,omitempty"`
+	BudgetAlerts             []models.BudgetAlert    `json:"budget_alerts,omitempty"`
+	Revaluation              *models.Revaluation     `json:"revaluation,omitempty"`
+	Member                   *models.RoomUser        `json:"member,omitempty"`
+	Invite                   *models.Invite          `json:"invite,omitempty"`
+	Room                     *models.Room            `json:"room,omitempty"`
+	AuditEntries             []models.RoomAuditEntry `json:"audit_entries,omitempty"`
+	Snapshot                 *models.RoomSnapshot    `json:"snapshot,omitempty"`
+	Claim                    *PlaceholderClaim       `json:"claim,omitempty"`
+	Household                *models.Household       `json:"household,omitempty"`
+}
+
+type ApprovalEvent struct {
+	Action  string    `json:"action"`
+	ItemID  uuid.UUID `json:"item_id"`
+	ActorID uuid.UUID `json:"actor_id"`
+	Status  string    `json:"status"`
+	Reason  string    `json:"reason,omitempty"`
 }
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"backend/algorithm"
+	"backend/fx"
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const (
+	HouseholdUpdatedEvent = "household_updated"
+	HouseholdDeletedEvent = "household_deleted"
+)
+
+// HouseholdRequest sets a household's name and, when MemberIDs is given,
+// replaces its members. Members move out of any household they were in.
+type HouseholdRequest struct {
+	Name      *string      `json:"name"`
+	MemberIDs *[]uuid.UUID `json:"member_ids"`
+}
+
+// SplitItem is an item of a split whose beneficiary or payer may be a whole
+// household. It is expanded into one item per member, dividing the amounts
+// evenly.
+type SplitItem struct {
+	models.Item
+	FromHouseholdID *uuid.UUID `json:"from_household_id"`
+	ToHouseholdID   *uuid.UUID `json:"to_household_id"`
+}
+
+// HouseholdBalance is a household's net position along with that of each of
+// its members.
+type HouseholdBalance struct {
+	models.Household
+	Balance        int64               `json:"balance"`
+	MemberBalances map[uuid.UUID]int64 `json:"member_balances"`
+	// DisplayBalance and DisplayMemberBalances hold the balances in the
+	// requested display currency.
+	DisplayBalance        *int64              `json:"display_balance,omitempty"`
+	DisplayMemberBalances map[uuid.UUID]int64 `json:"display_member_balances,omitempty"`
+}
+
+func (h *Handler) CreateHousehold(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.saveHousehold(w, r, ps, true)
+}
+
+func (h *Handler) UpdateHousehold(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.saveHousehold(w, r, ps, false)
+}
+
+func (h *Handler) saveHousehold(w http.ResponseWriter, r *http.Request, ps httprouter.Params, create bool) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermManageMembers); !ok {
+		return
+	}
+
+	var req HouseholdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+
+	household := models.Household{RoomID: roomID}
+	if !create {
+		if err := h.DB.First(&household, "id = ? AND room_id = ?", ps.ByName("householdID"), roomID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				http.Error(w, "HOUSEHOLD_NOT_FOUND", http.StatusNotFound)
+			} else {
+				http.Error(w, "DB_ERROR_HOUSEHOLDS", http.StatusInternalServerError)
+			}
+			return
+		}
+		if err := h.loadHouseholdMembers(&household); err != nil {
+			http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+			return
+		}
+	}
+	before := household
+
+	if req.Name != nil {
+		household.Name = strings.TrimSpace(*req.Name)
+	}
+	if len(household.Name) < 1 || len(household.Name) > 40 {
+		http.Error(w, "INVALID_NAME_LENGTH", http.StatusBadRequest)
+		return
+	}
+
+	if req.MemberIDs != nil {
+		var found int64
+		if err := h.DB.Model(&models.RoomUser{}).
+			Where("room_id = ? AND user_id IN ? AND status = ?", roomID, *req.MemberIDs, MemberIn).
+			Count(&found).Error; err != nil {
+			http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+			return
+		}
+		if int(found) != len(uniqueIDs(*req.MemberIDs)) {
+			http.Error(w, "USER_NOT_IN_ROOM", http.StatusBadRequest)
+			return
+		}
+		household.MemberIDs = uniqueIDs(*req.MemberIDs)
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&household).Error; err != nil {
+			return err
+		}
+		if req.MemberIDs == nil {
+			return nil
+		}
+		if err := tx.Model(&models.RoomUser{}).
+			Where("room_id = ? AND household_id = ?", roomID, household.ID).
+			Update("household_id", nil).Error; err != nil {
+			return err
+		}
+		if len(household.MemberIDs) == 0 {
+			return nil
+		}
+		return tx.Model(&models.RoomUser{}).
+			Where("room_id = ? AND user_id IN ?", roomID, household.MemberIDs).
+			Update("household_id", household.ID).Error
+	}); err != nil {
+		http.Error(w, "DB_ERROR_HOUSEHOLDS", http.StatusInternalServerError)
+		return
+	}
+
+	if create {
+		h.recordActivity(roomID, userID, ActivityHouseholdChanged, nil, household)
+	} else {
+		h.recordActivity(roomID, userID, ActivityHouseholdChanged, before, household)
+	}
+	info := &SSEUpdateInfo{
+		Event:     HouseholdUpdatedEvent,
+		Household: &household,
+	}
+	// a new membership changes how households settle
+	if req.MemberIDs != nil {
+		info.SimplifiedItems, _ = h.resimplify(roomID)
+	}
+	h.pushUpdatesToOtherClients(roomID.String(), userID.String(), info)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(household)
+}
+
+func (h *Handler) GetHouseholds(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomForMember(w, r, ps)
+	if !ok {
+		return
+	}
+
+	households, err := h.roomHouseholds(room.ID)
+	if err != nil {
+		http.Error(w, "DB_ERROR_HOUSEHOLDS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(households)
+}
+
+func (h *Handler) DeleteHousehold(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermManageMembers); !ok {
+		return
+	}
+
+	var household models.Household
+	if err := h.DB.First(&household, "id = ? AND room_id = ?", ps.ByName("householdID"), roomID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "HOUSEHOLD_NOT_FOUND", http.StatusNotFound)
+		} else {
+			http.Error(w, "DB_ERROR_HOUSEHOLDS", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RoomUser{}).
+			Where("room_id = ? AND household_id = ?", roomID, household.ID).
+			Update("household_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&household).Error
+	}); err != nil {
+		http.Error(w, "DB_ERROR_HOUSEHOLDS", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordActivity(roomID, userID, ActivityHouseholdChanged, household, nil)
+	simplifiedItems, _ := h.resimplify(roomID)
+	h.pushUpdatesToOtherClients(roomID.String(), userID.String(), &SSEUpdateInfo{
+		Event:           HouseholdDeletedEvent,
+		Household:       &household,
+		SimplifiedItems: simplifiedItems,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "HOUSEHOLD_DELETED"})
+}
+
+// writeHouseholdSettlements responds with a settlement plan in which every
+// household settles as one party, and with each household's balances. Like
+// the plan of individual members, it is cached unless the room floats, and
+// converted when a display currency is asked for.
+func (h *Handler) writeHouseholdSettlements(w http.ResponseWriter, r *http.Request, roomID uuid.UUID) {
+	var room models.Room
+	if err := h.DB.First(&room, "id = ?", roomID).Error; err != nil {
+		http.Error(w, "ROOM_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+	conversion, err := h.displayConversion(&room, r)
+	if err != nil {
+		writeError(w, err, "FX_PROVIDER_ERROR")
+		return
+	}
+
+	simplifiedItems, err := h.loadHouseholdItems(&room)
+	if err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+	items, err := h.approvedItems(&room)
+	if err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+	households, err := h.roomHouseholds(roomID)
+	if err != nil {
+		http.Error(w, "DB_ERROR_HOUSEHOLDS", http.StatusInternalServerError)
+		return
+	}
+
+	balances, err := balancesOf(items)
+	if err != nil {
+		http.Error(w, "BALANCE_OVERFLOW", http.StatusUnprocessableEntity)
+		return
+	}
+	res := []HouseholdBalance{}
+	for _, household := range households {
+		balance := HouseholdBalance{Household: household, MemberBalances: map[uuid.UUID]int64{}}
+		for _, memberID := range household.MemberIDs {
+			balance.MemberBalances[memberID] = balances[memberID]
+			balance.Balance += balances[memberID]
+		}
+		if conversion != nil {
+			if err := balance.display(conversion); err != nil {
+				writeError(w, err, "FX_CONVERSION_FAILED")
+				return
+			}
+		}
+		res = append(res, balance)
+	}
+
+	response := map[string]interface{}{
+		"simplifiedItems": simplifiedItems,
+		"households":      res,
+	}
+	if conversion != nil {
+		displayed, err := displaySimplifiedItems(simplifiedItems, conversion)
+		if err != nil {
+			writeError(w, err, "FX_CONVERSION_FAILED")
+			return
+		}
+		response["simplifiedItems"] = displayed
+		response["conversion"] = conversion
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (b *HouseholdBalance) display(quote *fx.Quote) error {
+	balance, err := displayAmount(b.Balance, quote)
+	if err != nil {
+		return err
+	}
+	if b.DisplayMemberBalances, err = displayBalances(b.MemberBalances, quote); err != nil {
+		return err
+	}
+	b.DisplayBalance = &balance
+	return nil
+}
+
+// loadHouseholdItems returns the room's household settlement plan, from the
+// cache unless the room floats with current exchange rates.
+func (h *Handler) loadHouseholdItems(room *models.Room) ([]models.SimplifiedItem, error) {
+	if cached, ok := h.RoomToHouseholdItems.Load(room.ID); ok && room.FxMode != FxModeFloating {
+		return cached.([]models.SimplifiedItem), nil
+	}
+	if _, err := h.resimplify(room.ID); err != nil {
+		return nil, err
+	}
+	cached, _ := h.RoomToHouseholdItems.Load(room.ID)
+	return cached.([]models.SimplifiedItem), nil
+}
+
+// simplifyHouseholds computes the plan in which households settle as one
+// party from the room's approved items. Without households it is the same as
+// the plan of individual members, simplifiedItems.
+func (h *Handler) simplifyHouseholds(roomID uuid.UUID, items []models.Item, algoType algorithm.AlgoType, simplifiedItems []models.SimplifiedItem) ([]models.SimplifiedItem, error) {
+	households, err := h.roomHouseholds(roomID)
+	if err != nil {
+		return nil, err
+	}
+	unitOf := map[uuid.UUID]uuid.UUID{}
+	for _, household := range households {
+		for _, memberID := range household.MemberIDs {
+			unitOf[memberID] = household.ID
+		}
+	}
+	if len(unitOf) == 0 {
+		return simplifiedItems, nil
+	}
+	return h.Simplifier.SimplifyItems(householdItems(items, unitOf), algoType), nil
+}
+
+// expandHouseholds turns a split into items between individual members.
+func (h *Handler) expandHouseholds(roomID uuid.UUID, splits []SplitItem) ([]models.Item, error) {
+	members := map[uuid.UUID][]uuid.UUID{}
+	load := func(householdID uuid.UUID) ([]uuid.UUID, error) {
+		if ids, ok := members[householdID]; ok {
+			return ids, nil
+		}
+		household := models.Household{}
+		if err := h.DB.First(&household, "id = ? AND room_id = ?", householdID, roomID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, &requestError{code: "HOUSEHOLD_NOT_FOUND", status: http.StatusBadRequest}
+			}
+			return nil, err
+		}
+		if err := h.loadHouseholdMembers(&household); err != nil {
+			return nil, err
+		}
+		if len(household.MemberIDs) == 0 {
+			return nil, &requestError{code: "HOUSEHOLD_EMPTY", status: http.StatusBadRequest}
+		}
+		members[householdID] = household.MemberIDs
+		return household.MemberIDs, nil
+	}
+
+	items := []models.Item{}
+	for _, split := range splits {
+		expanded := []models.Item{split.Item}
+		if split.FromHouseholdID != nil {
+			ids, err := load(*split.FromHouseholdID)
+			if err != nil {
+				return nil, err
+			}
+			if expanded, err = splitAcross(expanded, ids, func(item *models.Item, userID uuid.UUID) { item.FromUserID = userID }); err != nil {
+				return nil, err
+			}
+		}
+		if split.ToHouseholdID != nil {
+			ids, err := load(*split.ToHouseholdID)
+			if err != nil {
+				return nil, err
+			}
+			if expanded, err = splitAcross(expanded, ids, func(item *models.Item, userID uuid.UUID) { item.ToUserID = userID }); err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, expanded...)
+	}
+	return items, nil
+}
+
+// splitAcross replaces every item with one per user, dividing its amounts
+// evenly between them.
+func splitAcross(items []models.Item, userIDs []uuid.UUID, assign func(*models.Item, uuid.UUID)) ([]models.Item, error) {
+	res := []models.Item{}
+	for _, item := range items {
+		amounts, err := models.Money{Amount: item.Amount}.Split(len(userIDs))
+		if err != nil {
+			return nil, &requestError{code: "INVALID_AMOUNT", status: http.StatusBadRequest}
+		}
+		foreignAmounts, err := models.Money{Amount: item.ForeignAmount}.Split(len(userIDs))
+		if err != nil {
+			return nil, &requestError{code: "INVALID_AMOUNT", status: http.StatusBadRequest}
+		}
+		for i, userID := range userIDs {
+			part := item
+			part.Amount, part.ForeignAmount = amounts[i].Amount, foreignAmounts[i].Amount
+			assign(&part, userID)
+			res = append(res, part)
+		}
+	}
+	return res, nil
+}
+
+// householdItems returns copies of items with every member of a household
+// replaced by the household, so that simplification treats it as one party.
+func householdItems(items []models.Item, unitOf map[uuid.UUID]uuid.UUID) []models.Item {
+	res := make([]models.Item, len(items))
+	copy(res, items)
+	for i := range res {
+		if unit, ok := unitOf[res[i].FromUserID]; ok {
+			res[i].FromUserID = unit
+		}
+		if unit, ok := unitOf[res[i].ToUserID]; ok {
+			res[i].ToUserID = unit
+		}
+	}
+	return res
+}
+
+func (h *Handler) roomHouseholds(roomID uuid.UUID) ([]models.Household, error) {
+	households := []models.Household{}
+	if err := h.DB.Where("room_id = ?", roomID).Order("name ASC").Find(&households).Error; err != nil {
+		return nil, err
+	}
+	for i := range households {
+		if err := h.loadHouseholdMembers(&households[i]); err != nil {
+			return nil, err
+		}
+	}
+	return households, nil
+}
+
+func (h *Handler) loadHouseholdMembers(household *models.Household) error {
+	household.MemberIDs = []uuid.UUID{}
+	return h.DB.Model(&models.RoomUser{}).
+		Where("household_id = ? AND status = ?", household.ID, MemberIn).
+		Order("user_id ASC").
+		Pluck("user_id", &household.MemberIDs).Error
+}
+
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := map[uuid.UUID]bool{}
+	res := []uuid.UUID{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			res = append(res, id)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].String() < res[j].String() })
+	return res
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"backend/algorithm"
+	"backend/models"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitAcross(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	toUser := func(item *models.Item, userID uuid.UUID) { item.ToUserID = userID }
+
+	for _, tc := range []struct {
+		name           string
+		amount         int64
+		foreignAmount  int64
+		userIDs        []uuid.UUID
+		amounts        []int64
+		foreignAmounts []int64
+	}{
+		{"even", 900, 0, []uuid.UUID{a, b, c}, []int64{300, 300, 300}, []int64{0, 0, 0}},
+		// the remainder goes one cent at a time to the first members
+		{"remainder", 1000, 0, []uuid.UUID{a, b, c}, []int64{334, 333, 333}, []int64{0, 0, 0}},
+		{"remainder of two", 1001, 0, []uuid.UUID{a, b, c}, []int64{334, 334, 333}, []int64{0, 0, 0}},
+		{"negative remainder", -1000, 0, []uuid.UUID{a, b, c}, []int64{-334, -333, -333}, []int64{0, 0, 0}},
+		{"less than a cent each", 2, 0, []uuid.UUID{a, b, c}, []int64{1, 1, 0}, []int64{0, 0, 0}},
+		{"foreign amounts split alike", 1000, 1201, []uuid.UUID{a, b}, []int64{500, 500}, []int64{601, 600}},
+		{"one member", 1000, 0, []uuid.UUID{a}, []int64{1000}, []int64{0}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			items, err := splitAcross([]models.Item{{Amount: tc.amount, ForeignAmount: tc.foreignAmount}}, tc.userIDs, toUser)
+			require.NoError(t, err)
+			require.Len(t, items, len(tc.userIDs))
+
+			var amounts, foreignAmounts []int64
+			for i, item := range items {
+				assert.Equal(t, tc.userIDs[i], item.ToUserID)
+				amounts = append(amounts, item.Amount)
+				foreignAmounts = append(foreignAmounts, item.ForeignAmount)
+			}
+			assert.Equal(t, tc.amounts, amounts)
+			assert.Equal(t, tc.foreignAmounts, foreignAmounts)
+		})
+	}
+
+	_, err := splitAcross([]models.Item{{Amount: 1000}}, nil, toUser)
+	assert.EqualError(t, err, "INVALID_AMOUNT")
+}
+
+func TestHouseholdItems_Netting(t *testing.T) {
+	a1, a2, b1, b2, c := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	householdA, householdB := uuid.New(), uuid.New()
+	unitOf := map[uuid.UUID]uuid.UUID{a1: householdA, a2: householdA, b1: householdB, b2: householdB}
+	owes := func(from, to uuid.UUID, amount int64) models.Item {
+		return models.Item{FromUserID: from, ToUserID: to, Amount: amount}
+	}
+	simplifier := &algorithm.Simplifier{}
+
+	for _, tc := range []struct {
+		name  string
+		items []models.Item
+		want  []models.SimplifiedItem
+	}{
+		{"within a household", []models.Item{owes(a1, a2, 1000)}, nil},
+		{"between households", []models.Item{owes(a1, b1, 3000), owes(b2, a2, 1000)},
+			[]models.SimplifiedItem{{FromUserID: householdA, ToUserID: householdB, Amount: 2000}}},
+		{"members cancel out", []models.Item{owes(a1, b1, 1000), owes(b2, a2, 1000)}, nil},
+		{"through someone outside", []models.Item{owes(c, a1, 500), owes(a2, c, 500)}, nil},
+		{"three parties", []models.Item{owes(a1, c, 1000), owes(c, b2, 400), owes(b1, a2, 100)},
+			[]models.SimplifiedItem{
+				{FromUserID: householdA, ToUserID: c, Amount: 600},
+				{FromUserID: householdA, ToUserID: householdB, Amount: 300},
+			}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			simplified := simplifier.SimplifyItems(householdItems(tc.items, unitOf), algorithm.Greedy)
+			var got []models.SimplifiedItem
+			for _, item := range simplified {
+				got = append(got, models.SimplifiedItem{FromUserID: item.FromUserID, ToUserID: item.ToUserID, Amount: item.Amount})
+			}
+			assert.ElementsMatch(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"backend/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const MaxInviteLifetime = 30 * 24 * time.Hour
+
+const (
+	InviteCreatedEvent  = "invite_created"
+	InviteRevokedEvent  = "invite_revoked"
+	MemberPendingEvent  = "member_pending"
+	MemberApprovedEvent = "member_approved"
+	MemberRejectedEvent = "member_rejected"
+)
+
+type CreateInviteRequest struct {
+	// ExpiresInHours defaults to a week; longer than MaxInviteLifetime is capped.
+	ExpiresInHours int `json:"expires_in_hours"`
+	MaxUses        int `json:"max_uses"`
+	// PlaceholderID makes a single-use invite for claiming that placeholder.
+	PlaceholderID *uuid.UUID `json:"placeholder_id"`
+}
+
+type JoinRoomRequest struct {
+	Invite string `json:"invite"`
+}
+
+type InviteResponse struct {
+	models.Invite
+	Token string `json:"token"`
+}
+
+func (h *Handler) CreateInvite(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermManageMembers); !ok {
+		return
+	}
+
+	var req CreateInviteRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = 7 * 24
+	}
+	lifetime, err := inviteLifetime(req.ExpiresInHours)
+	if err != nil {
+		writeError(w, err, "INVALID_EXPIRY")
+		return
+	}
+	if req.MaxUses < 0 {
+		http.Error(w, "INVALID_MAX_USES", http.StatusBadRequest)
+		return
+	}
+	if req.PlaceholderID != nil {
+		if err := h.DB.First(&models.User{}, "id = ? AND placeholder_room_id = ?", *req.PlaceholderID, roomID).Error; err != nil {
+			http.Error(w, "PLACEHOLDER_NOT_FOUND", http.StatusNotFound)
+			return
+		}
+		req.MaxUses = 1
+	}
+
+	expiresAt := time.Now().Add(lifetime)
+	invite := models.Invite{
+		RoomID:        roomID,
+		CreatorID:     userID,
+		ExpiresAt:     &expiresAt,
+		MaxUses:       req.MaxUses,
+		PlaceholderID: req.PlaceholderID,
+	}
+	if err := h.DB.Create(&invite).Error; err != nil {
+		http.Error(w, "DB_ERROR_INVITES", http.StatusInternalServerError)
+		return
+	}
+
+	h.pushUpdatesToOtherClients(roomID.String(), userID.String(), &SSEUpdateInfo{
+		Event:  InviteCreatedEvent,
+		Invite: &invite,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(InviteResponse{Invite: invite, Token: h.inviteToken(invite.ID)})
+}
+
+// inviteLifetime turns a requested number of hours into a lifetime, capped at
+// MaxInviteLifetime before it can overflow.
+func inviteLifetime(hours int) (time.Duration, error) {
+	if hours < 0 {
+		return 0, &requestError{code: "INVALID_EXPIRY", status: http.StatusBadRequest}
+	}
+	if hours > int(MaxInviteLifetime/time.Hour) {
+		return MaxInviteLifetime, nil
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+// decodeOptionalBody decodes a JSON request body into v, leaving v as it is
+// when there is no body, so that every field takes its default.
+func decodeOptionalBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func (h *Handler) GetInvites(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermManageMembers); !ok {
+		return
+	}
+
+	var invites []models.Invite
+	if err := h.DB.Where("room_id = ?", roomID).Order("created_at DESC").Find(&invites).Error; err != nil {
+		http.Error(w, "DB_ERROR_INVITES", http.StatusInternalServerError)
+		return
+	}
+
+	res := []InviteResponse{}
+	for _, invite := range invites {
+		res = append(res, InviteResponse{Invite: invite, Token: h.inviteToken(invite.ID)})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (h *Handler) RevokeInvite(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermManageMembers); !ok {
+		return
+	}
+
+	var invite models.Invite
+	if err := h.DB.Where("id = ? AND room_id = ?", ps.ByName("inviteID"), roomID).First(&invite).Error; err != nil {
+		http.Error(w, "INVITE_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+	if invite.RevokedAt == nil {
+		now := time.Now()
+		invite.RevokedAt = &now
+		if err := h.DB.Model(&invite).Update("revoked_at", now).Error; err != nil {
+			http.Error(w, "DB_ERROR_INVITES", http.StatusInternalServerError)
+			return
+		}
+		h.pushUpdatesToOtherClients(roomID.String(), userID.String(), &SSEUpdateInfo{
+			Event:  InviteRevokedEvent,
+			Invite: &invite,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invite)
+}
+
+// GetInvite previews the room an invite link leads to without joining it.
+func (h *Handler) GetInvite(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	invite, err := h.checkInvite(ps.ByName("token"))
+	if err != nil {
+		writeError(w, err, "DB_ERROR_INVITES")
+		return
+	}
+
+	var room models.Room
+	if err := h.DB.First(&room, "id = ?", invite.RoomID).Error; err != nil {
+		http.Error(w, "ROOM_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"roomID":              room.ID,
+		"roomName":            room.Name,
+		"requireJoinApproval": room.RequireJoinApproval,
+		"expiresAt":           invite.ExpiresAt,
+	}
+	if invite.PlaceholderID != nil {
+		var placeholder models.User
+		if err := h.DB.Select("id", "name").First(&placeholder, "id = ?", *invite.PlaceholderID).Error; err == nil {
+			response["placeholderName"] = placeholder.Name
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *Handler) ApproveMember(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.decideJoinRequest(w, r, ps, true)
+}
+
+func (h *Handler) RejectMember(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.decideJoinRequest(w, r, ps, false)
+}
+
+func (h *Handler) decideJoinRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params, approve bool) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermManageMembers); !ok {
+		return
+	}
+
+	var roomUser models.RoomUser
+	if err := h.DB.Where("room_id = ? AND user_id = ? AND status = ?", roomID, ps.ByName("userID"), MemberPending).First(&roomUser).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "JOIN_REQUEST_NOT_FOUND", http.StatusNotFound)
+		} else {
+			http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	event := MemberRejectedEvent
+	roomUser.Status = MemberRejected
+	if approve {
+		roomUser.Status, event = MemberIn, MemberApprovedEvent
+	}
+	if err := h.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id = ?", roomID, roomUser.UserID).
+		Update("status", roomUser.Status).Error; err != nil {
+		http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		return
+	}
+
+	action := ActivityMemberRejected
+	if approve {
+		action = ActivityMemberApproved
+	}
+	h.recordActivity(roomID, userID, action, nil, roomUser)
+
+	info := &SSEUpdateInfo{Event: event, Member: &roomUser}
+	if approve {
+		var user models.User
+		if err := h.DB.Select("id", "name").First(&user, "id = ?", roomUser.UserID).Error; err == nil {
+			info.NewUser = &user
+		}
+	}
+	h.pushUpdatesToAllClients(roomID.String(), info)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roomUser)
+}
+
+func (h *Handler) writeJoinResponse(w http.ResponseWriter, room models.Room, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	if status == MemberPending {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"room":   room,
+			"status": status,
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(room)
+}
+
+// redeemInvite checks the invite for roomID and counts one use of it in db.
+func (h *Handler) redeemInvite(db *gorm.DB, roomID uuid.UUID, token string) error {
+	if token == "" {
+		return &requestError{code: "INVITE_REQUIRED", status: http.StatusForbidden}
+	}
+	invite, err := h.checkInvite(token)
+	if err != nil {
+		return err
+	}
+	if invite.RoomID != roomID {
+		return &requestError{code: "INVALID_INVITE", status: http.StatusForbidden}
+	}
+
+	// the guard keeps concurrent joins from exceeding MaxUses
+	result := db.Model(&models.Invite{}).
+		Where("id = ? AND (max_uses = 0 OR uses < max_uses)", invite.ID).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &requestError{code: "INVITE_EXHAUSTED", status: http.StatusGone}
+	}
+	return nil
+}
+
+// checkInvite verifies the signature of token and that its invite is still
+// usable.
+func (h *Handler) checkInvite(token string) (*models.Invite, error) {
+	invalid := &requestError{code: "INVALID_INVITE", status: http.StatusForbidden}
+
+	idPart, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, invalid
+	}
+	inviteID, err := uuid.Parse(idPart)
+	if err != nil || !hmac.Equal([]byte(h.inviteToken(inviteID)), []byte(idPart+"."+signature)) {
+		return nil, invalid
+	}
+
+	var invite models.Invite
+	if err := h.DB.First(&invite, "id = ?", inviteID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, invalid
+		}
+		return nil, err
+	}
+
+	switch {
+	case invite.RevokedAt != nil:
+		return nil, &requestError{code: "INVITE_REVOKED", status: http.StatusGone}
+	case invite.ExpiresAt != nil && time.Now().After(*invite.ExpiresAt):
+		return nil, &requestError{code: "INVITE_EXPIRED", status: http.StatusGone}
+	case invite.MaxUses > 0 && invite.Uses >= invite.MaxUses:
+		return nil, &requestError{code: "INVITE_EXHAUSTED", status: http.StatusGone}
+	}
+	return &invite, nil
+}
+
+// inviteToken signs the invite ID with the server key, so that links cannot
+// be forged from a room or invite ID alone.
+func (h *Handler) inviteToken(inviteID uuid.UUID) string {
+	mac := hmac.New(sha256.New, h.Auth.JWTKey)
+	mac.Write([]byte("invite:" + inviteID.String()))
+	return inviteID.String() + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInviteLifetime(t *testing.T) {
+	for hours, want := range map[int]time.Duration{
+		0:             0,
+		24:            24 * time.Hour,
+		30 * 24:       MaxInviteLifetime,
+		30*24 + 1:     MaxInviteLifetime,
+		math.MaxInt64: MaxInviteLifetime,
+		// wraps time.Duration around to about 25 minutes unless capped first
+		5124096: MaxInviteLifetime,
+	} {
+		lifetime, err := inviteLifetime(hours)
+		assert.NoError(t, err, hours)
+		assert.Equal(t, want, lifetime, hours)
+	}
+
+	_, err := inviteLifetime(-1)
+	assert.EqualError(t, err, "INVALID_EXPIRY")
+}
+
+func TestDecodeOptionalBody(t *testing.T) {
+	var req CreateInviteRequest
+	assert.NoError(t, decodeOptionalBody(httptest.NewRequest("POST", "/", nil), &req))
+	assert.Equal(t, CreateInviteRequest{}, req)
+
+	assert.NoError(t, decodeOptionalBody(httptest.NewRequest("POST", "/", strings.NewReader(`{"max_uses": 3}`)), &req))
+	assert.Equal(t, 3, req.MaxUses)
+
+	assert.Error(t, decodeOptionalBody(httptest.NewRequest("POST", "/", strings.NewReader(`{"max_uses":`)), &req))
+}
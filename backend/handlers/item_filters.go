@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultItemPageSize = 50
+	MaxItemPageSize     = 200
+)
+
+// ItemFilter holds the query parameters accepted by GetItems.
+type ItemFilter struct {
+	UserID          *uuid.UUID
+	TransactionType string
+	Category        string
+	Status          string
+	From            *time.Time
+	To              *time.Time
+	MinAmount       *int64
+	MaxAmount       *int64
+	Search          string
+	Limit           int
+	Cursor          *itemCursor
+}
+
+// itemCursor points at the last item of a page; the next page starts
+// strictly after it in (occurred_at, id) order.
+type itemCursor struct {
+	OccurredAt time.Time
+	ID         uuid.UUID
+}
+
+func parseItemFilter(q url.Values) (ItemFilter, error) {
+	f := ItemFilter{Limit: DefaultItemPageSize}
+
+	if s := q.Get("user"); s != "" {
+		userID, err := uuid.Parse(s)
+		if err != nil {
+			return f, errors.New("INVALID_USER_ID")
+		}
+		f.UserID = &userID
+	}
+
+	if s := q.Get("type"); s != "" {
+		s = strings.ToUpper(s)
+		if s != Expense && s != Income && s != Transfer {
+			return f, errors.New("INVALID_TRANSACTION_TYPE")
+		}
+		f.TransactionType = s
+	}
+
+	f.Category = strings.TrimSpace(q.Get("category"))
+
+	if s := q.Get("status"); s != "" {
+		s = strings.ToUpper(s)
+		if s != ItemApproved && s != ItemPending && s != ItemDisputed {
+			return f, errors.New("INVALID_STATUS")
+		}
+		f.Status = s
+	}
+
+	if s := q.Get("from"); s != "" {
+		from, _, err := parseDateParam(s)
+		if err != nil {
+			return f, errors.New("INVALID_FROM_DATE")
+		}
+		f.From = &from
+	}
+
+	if s := q.Get("to"); s != "" {
+		to, dateOnly, err := parseDateParam(s)
+		if err != nil {
+			return f, errors.New("INVALID_TO_DATE")
+		}
+		// a bare date includes the whole day
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		} else {
+			to = to.Add(time.Nanosecond)
+		}
+		f.To = &to
+	}
+
+	if s := q.Get("min_amount"); s != "" {
+		amount, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return f, errors.New("INVALID_MIN_AMOUNT")
+		}
+		f.MinAmount = &amount
+	}
+
+	if s := q.Get("max_amount"); s != "" {
+		amount, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return f, errors.New("INVALID_MAX_AMOUNT")
+		}
+		f.MaxAmount = &amount
+	}
+
+	f.Search = strings.TrimSpace(q.Get("q"))
+
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			return f, errors.New("INVALID_LIMIT")
+		}
+		f.Limit = min(limit, MaxItemPageSize)
+	}
+
+	if s := q.Get("cursor"); s != "" {
+		cursor, err := decodeItemCursor(s)
+		if err != nil {
+			return f, errors.New("INVALID_CURSOR")
+		}
+		f.Cursor = &cursor
+	}
+
+	return f, nil
+}
+
+// Apply adds the filter conditions to a query over the items table. Ordering
+// and limits are left to the caller.
+func (f ItemFilter) Apply(db *gorm.DB) *gorm.DB {
+	if f.UserID != nil {
+		db = db.Where("(from_user_id = ? OR to_user_id = ?)", *f.UserID, *f.UserID)
+	}
+	if f.TransactionType != "" {
+		db = db.Where("transaction_type = ?", f.TransactionType)
+	}
+	if f.Category != "" {
+		db = db.Where("LOWER(category) = LOWER(?)", f.Category)
+	}
+	if f.Status != "" {
+		db = db.Where("status = ?", f.Status)
+	}
+	if f.From != nil {
+		db = db.Where("occurred_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		db = db.Where("occurred_at < ?", *f.To)
+	}
+	if f.MinAmount != nil {
+		db = db.Where("amount >= ?", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		db = db.Where("amount <= ?", *f.MaxAmount)
+	}
+	if f.Search != "" {
+		db = db.Where("to_tsvector('simple', content) @@ plainto_tsquery('simple', ?)", f.Search)
+	}
+	if f.Cursor != nil {
+		db = db.Where("(occurred_at, id) > (?, ?)", f.Cursor.OccurredAt, f.Cursor.ID)
+	}
+	return db
+}
+
+// pageItems cuts items, fetched with one extra row, down to a page of limit
+// items. The cursor is empty on the last page.
+func pageItems(items []models.Item, limit int) ([]models.Item, string) {
+	if len(items) <= limit {
+		return items, ""
+	}
+	items = items[:limit]
+	last := items[len(items)-1]
+	return items, encodeItemCursor(itemCursor{OccurredAt: last.OccurredAt, ID: last.ID})
+}
+
+func parseDateParam(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, false, err
+}
+
+func encodeItemCursor(c itemCursor) string {
+	raw := c.OccurredAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeItemCursor(s string) (itemCursor, error) {
+	var c itemCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	occurredAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return c, errors.New("malformed cursor")
+	}
+	if c.OccurredAt, err = time.Parse(time.RFC3339Nano, occurredAt); err != nil {
+		return c, err
+	}
+	if c.ID, err = uuid.Parse(id); err != nil {
+		return c, err
+	}
+	return c, nil
+}
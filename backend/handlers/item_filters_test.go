@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"backend/models"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB builds SQL without connecting, so queries can be checked as text.
+func dryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.NoError(t, err)
+	return db
+}
+
+func TestParseItemFilter(t *testing.T) {
+	userID := uuid.New()
+	f, err := parseItemFilter(url.Values{
+		"user":       {userID.String()},
+		"type":       {"expense"},
+		"category":   {" Food "},
+		"status":     {"pending"},
+		"from":       {"2024-03-01"},
+		"to":         {"2024-03-31"},
+		"min_amount": {"100"},
+		"max_amount": {"5000"},
+		"q":          {"pizza"},
+		"limit":      {"1000"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, userID, *f.UserID)
+	assert.Equal(t, Expense, f.TransactionType)
+	assert.Equal(t, "Food", f.Category)
+	assert.Equal(t, ItemPending, f.Status)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), *f.From)
+	// a bare to date includes that whole day
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), *f.To)
+	assert.Equal(t, int64(100), *f.MinAmount)
+	assert.Equal(t, int64(5000), *f.MaxAmount)
+	assert.Equal(t, "pizza", f.Search)
+	assert.Equal(t, MaxItemPageSize, f.Limit)
+}
+
+func TestParseItemFilter_Defaults(t *testing.T) {
+	f, err := parseItemFilter(url.Values{})
+	assert.NoError(t, err)
+	assert.Equal(t, ItemFilter{Limit: DefaultItemPageSize}, f)
+}
+
+func TestParseItemFilter_Invalid(t *testing.T) {
+	for param, want := range map[string]string{
+		"user":       "INVALID_USER_ID",
+		"type":       "INVALID_TRANSACTION_TYPE",
+		"status":     "INVALID_STATUS",
+		"from":       "INVALID_FROM_DATE",
+		"to":         "INVALID_TO_DATE",
+		"min_amount": "INVALID_MIN_AMOUNT",
+		"max_amount": "INVALID_MAX_AMOUNT",
+		"limit":      "INVALID_LIMIT",
+		"cursor":     "INVALID_CURSOR",
+	} {
+		_, err := parseItemFilter(url.Values{param: {"bogus"}})
+		assert.EqualError(t, err, want, param)
+	}
+
+	_, err := parseItemFilter(url.Values{"limit": {"0"}})
+	assert.EqualError(t, err, "INVALID_LIMIT")
+}
+
+func TestItemFilter_Apply(t *testing.T) {
+	db := dryRunDB(t)
+	userID := uuid.New()
+	minAmount := int64(100)
+	cursor := itemCursor{OccurredAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), ID: uuid.New()}
+	f := ItemFilter{UserID: &userID, Category: "food", MinAmount: &minAmount, Cursor: &cursor}
+
+	var items []models.Item
+	stmt := f.Apply(db.Where("room_id = ?", "r")).Find(&items).Statement
+	assert.Equal(t,
+		`SELECT * FROM "items" WHERE room_id = $1 AND ((from_user_id = $2 OR to_user_id = $3)) AND LOWER(category) = LOWER($4) AND amount >= $5 AND (occurred_at, id) > ($6, $7)`,
+		stmt.SQL.String())
+	assert.Equal(t, []interface{}{"r", userID, userID, "food", minAmount, cursor.OccurredAt, cursor.ID}, stmt.Vars)
+}
+
+func TestItemCursor_RoundTrip(t *testing.T) {
+	c := itemCursor{OccurredAt: time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC), ID: uuid.New()}
+	decoded, err := decodeItemCursor(encodeItemCursor(c))
+	assert.NoError(t, err)
+	assert.True(t, c.OccurredAt.Equal(decoded.OccurredAt))
+	assert.Equal(t, c.ID, decoded.ID)
+
+	for _, s := range []string{"!!", "bm8tc2VwYXJhdG9y", "bm90LWEtdGltZXx4"} {
+		_, err := decodeItemCursor(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestPageItems(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]models.Item, 4)
+	for i := range items {
+		items[i] = models.Item{ID: uuid.New(), OccurredAt: start.Add(time.Duration(i) * time.Hour)}
+	}
+
+	page, next := pageItems(items, 3)
+	assert.Equal(t, items[:3], page)
+	cursor, err := decodeItemCursor(next)
+	assert.NoError(t, err)
+	assert.Equal(t, items[2].ID, cursor.ID)
+	assert.True(t, items[2].OccurredAt.Equal(cursor.OccurredAt))
+
+	page, next = pageItems(items[:3], 3)
+	assert.Len(t, page, 3)
+	assert.Empty(t, next)
+}
@@ -5,6 +5,8 @@ import (
 	"backend/models"
 	"encoding/json"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
@@ -17,35 +19,79 @@ const (
 	Transfer string = "TRANSFER"
 )
 
+const (
+	ItemApproved string = "APPROVED"
+	ItemPending  string = "PENDING"
+	ItemDisputed string = "DISPUTED"
+)
+
 const (
 	DefaultAlgo = algorithm.Greedy
+	// MaxOccurredAtSkew is how far in the future an item may be dated, to
+	// allow for clocks that run ahead.
+	MaxOccurredAtSkew = 5 * time.Minute
 )
 
 type CreateGroupExpenseRequest struct {
-	Items []models.Item `json:"items"`
+	Items []SplitItem `json:"items"`
 }
 
 type CreateGroupIncomeRequest struct {
-	Items []models.Item `json:"items"`
+	Items []SplitItem `json:"items"`
 }
 
 func (h *Handler) GetItems(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	roomID := ps.ByName("roomID")
-	var items []models.Item
-	if err := h.DB.Where("room_id = ?", roomID).Order("created_at ASC").Find(&items).Error; err != nil {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if !h.requireRoomMember(w, roomID, userID) {
+		return
+	}
+
+	filter, err := parseItemFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// fetch one extra row to know whether another page exists
+	items := []models.Item{}
+	query := filter.Apply(h.DB.Where("room_id = ?", roomID))
+	if err := query.Order("occurred_at ASC, id ASC").Limit(filter.Limit + 1).Find(&items).Error; err != nil {
 		http.Error(w, "DB_ERROR_ROOM_ITEMS", http.StatusInternalServerError)
 		return
 	}
 
+	items, nextCursor := pageItems(items, filter.Limit)
+
+	response := map[string]interface{}{
+		"items":      items,
+		"nextCursor": nextCursor,
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(items)
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *Handler) DeleteItem(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	itemID := ps.ByName("itemID")
 
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+	roomUser, ok := h.requireRoomPermission(w, roomID, r.Context().Value("userID").(uuid.UUID), PermEditOwnItems)
+	if !ok {
+		return
+	}
+
 	var deletedItem models.Item
-	if err := h.DB.Where("id = ?", itemID).First(&deletedItem).Error; err != nil {
+	if err := h.DB.Where("id = ? AND room_id = ?", itemID, roomID).First(&deletedItem).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "ITEM_NOT_FOUND", http.StatusNotFound)
 		} else {
@@ -53,14 +99,19 @@ func (h *Handler) DeleteItem(w http.ResponseWriter, r *http.Request, ps httprout
 		}
 		return
 	}
+	if !canEditItem(roomUser, deletedItem) {
+		http.Error(w, "PERMISSION_DENIED", http.StatusForbidden)
+		return
+	}
 
 	if err := h.DB.Delete(&models.Item{}, "id = ?", itemID).Error; err != nil {
 		http.Error(w, "DB_ERROR_ITEMS", http.StatusNotFound)
 		return
 	}
 
-	roomID, _ := uuid.Parse(ps.ByName("roomID"))
-	simplifiedItems, _ := h.simplifyAndStore(roomID, DefaultAlgo)
+	h.deleteOrphanedAttachments(roomID, deletedItem.GroupID)
+	simplifiedItems, _ := h.resimplify(roomID)
+	h.recordActivity(roomID, roomUser.UserID, ActivityItemsDeleted, []models.Item{deletedItem}, nil)
 
 	userIDStr := r.Context().Value("userID").(uuid.UUID).String()
 	info := &SSEUpdateInfo{
@@ -80,8 +131,18 @@ func (h *Handler) DeleteItem(w http.ResponseWriter, r *http.Request, ps httprout
 func (h *Handler) DeleteGroupedItems(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	groupID := ps.ByName("groupID")
 
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+	roomUser, ok := h.requireRoomPermission(w, roomID, r.Context().Value("userID").(uuid.UUID), PermEditOwnItems)
+	if !ok {
+		return
+	}
+
 	var deletedItems []models.Item
-	if err := h.DB.Where("group_id = ?", groupID).Find(&deletedItems).Error; err != nil {
+	if err := h.DB.Where("group_id = ? AND room_id = ?", groupID, roomID).Find(&deletedItems).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "GROUPID_NOT_FOUND", http.StatusNotFound)
 		} else {
@@ -89,14 +150,23 @@ func (h *Handler) DeleteGroupedItems(w http.ResponseWriter, r *http.Request, ps
 		}
 		return
 	}
+	for _, item := range deletedItems {
+		if !canEditItem(roomUser, item) {
+			http.Error(w, "PERMISSION_DENIED", http.StatusForbidden)
+			return
+		}
+	}
 
-	if err := h.DB.Delete(&models.Item{}, "group_id = ?", groupID).Error; err != nil {
+	if err := h.DB.Delete(&models.Item{}, "group_id = ? AND room_id = ?", groupID, roomID).Error; err != nil {
 		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
 		return
 	}
 
-	roomID, _ := uuid.Parse(ps.ByName("roomID"))
-	simplifiedItems, _ := h.simplifyAndStore(roomID, DefaultAlgo)
+	if groupUUID, err := uuid.Parse(groupID); err == nil {
+		h.deleteOrphanedAttachments(roomID, groupUUID)
+	}
+	simplifiedItems, _ := h.resimplify(roomID)
+	h.recordActivity(roomID, roomUser.UserID, ActivityItemsDeleted, deletedItems, nil)
 
 	userIDStr := r.Context().Value("userID").(uuid.UUID).String()
 	info := &SSEUpdateInfo{
@@ -120,6 +190,12 @@ func (h *Handler) CreateTransfer(w http.ResponseWriter, r *http.Request, ps http
 		return
 	}
 
+	// transfers settle debts, so they can still be recorded in archived rooms
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermRecordSettlements); !ok {
+		return
+	}
+
 	var item models.Item
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
 		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
@@ -130,18 +206,28 @@ func (h *Handler) CreateTransfer(w http.ResponseWriter, r *http.Request, ps http
 	item.RoomID = roomID
 	item.TransactionType = Transfer
 
+	newItems := []models.Item{item}
+	if err := h.prepareNewItems(roomID, userID, newItems); err != nil {
+		writeError(w, err, "DB_ERROR_ITEMS")
+		return
+	}
+	item = newItems[0]
+
 	if err := h.DB.Create(&item).Error; err != nil {
 		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
 	}
 
-	simplifiedItems, _ := h.simplifyAndStore(roomID, DefaultAlgo)
+	simplifiedItems, _ := h.resimplify(roomID)
+	h.recordActivity(roomID, userID, ActivitySettlementRecorded, nil, []models.Item{item})
 
-	userIDStr := r.Context().Value("userID").(uuid.UUID).String()
+	userIDStr := userID.String()
 	info := &SSEUpdateInfo{
 		NewItems:        []models.Item{item},
 		SimplifiedItems: simplifiedItems,
 	}
 	h.pushUpdatesToOtherClients(ps.ByName("roomID"), userIDStr, info)
+	h.checkBudgets(roomID, []models.Item{item})
 
 	response := map[string]interface{}{
 		"newItem":         item,
@@ -159,35 +245,54 @@ func (h *Handler) CreateGroupExpense(w http.ResponseWriter, r *http.Request, ps
 		return
 	}
 
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermAddItems); !ok {
+		return
+	}
+
 	var req CreateGroupExpenseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
 		return
 	}
 
+	items, err := h.expandHouseholds(roomID, req.Items)
+	if err != nil {
+		writeError(w, err, "DB_ERROR_HOUSEHOLDS")
+		return
+	}
+
 	groupID := uuid.New()
 
-	for i := range req.Items {
-		req.Items[i].RoomID = roomID
-		req.Items[i].GroupID = groupID
-		req.Items[i].TransactionType = Expense
+	for i := range items {
+		items[i].RoomID = roomID
+		items[i].GroupID = groupID
+		items[i].TransactionType = Expense
+	}
+
+	if err := h.prepareNewItems(roomID, userID, items); err != nil {
+		writeError(w, err, "DB_ERROR_ITEMS")
+		return
 	}
 
-	if err := h.DB.Create(&req.Items).Error; err != nil {
+	if err := h.DB.Create(&items).Error; err != nil {
 		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
 	}
 
-	simplifiedItems, _ := h.simplifyAndStore(roomID, DefaultAlgo)
+	simplifiedItems, _ := h.resimplify(roomID)
+	h.recordActivity(roomID, userID, ActivityItemsCreated, nil, items)
 
-	userIDStr := r.Context().Value("userID").(uuid.UUID).String()
+	userIDStr := userID.String()
 	info := &SSEUpdateInfo{
-		NewItems:        req.Items,
+		NewItems:        items,
 		SimplifiedItems: simplifiedItems,
 	}
 	h.pushUpdatesToOtherClients(ps.ByName("roomID"), userIDStr, info)
+	h.checkBudgets(roomID, items)
 
 	response := map[string]interface{}{
-		"newItems":        req.Items,
+		"newItems":        items,
 		"simplifiedItems": simplifiedItems,
 	}
 
@@ -202,35 +307,54 @@ func (h *Handler) CreateGroupIncome(w http.ResponseWriter, r *http.Request, ps h
 		return
 	}
 
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermAddItems); !ok {
+		return
+	}
+
 	var req CreateGroupIncomeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
 		return
 	}
 
+	items, err := h.expandHouseholds(roomID, req.Items)
+	if err != nil {
+		writeError(w, err, "DB_ERROR_HOUSEHOLDS")
+		return
+	}
+
 	groupID := uuid.New()
 
-	for i := range req.Items {
-		req.Items[i].RoomID = roomID
-		req.Items[i].GroupID = groupID
-		req.Items[i].TransactionType = Income
+	for i := range items {
+		items[i].RoomID = roomID
+		items[i].GroupID = groupID
+		items[i].TransactionType = Income
 	}
 
-	if err := h.DB.Create(&req.Items).Error; err != nil {
+	if err := h.prepareNewItems(roomID, userID, items); err != nil {
+		writeError(w, err, "DB_ERROR_ITEMS")
+		return
+	}
+
+	if err := h.DB.Create(&items).Error; err != nil {
 		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
 	}
 
-	simplifiedItems, _ := h.simplifyAndStore(roomID, DefaultAlgo)
+	simplifiedItems, _ := h.resimplify(roomID)
+	h.recordActivity(roomID, userID, ActivityItemsCreated, nil, items)
 
-	userIDStr := r.Context().Value("userID").(uuid.UUID).String()
+	userIDStr := userID.String()
 	info := &SSEUpdateInfo{
-		NewItems:        req.Items,
+		NewItems:        items,
 		SimplifiedItems: simplifiedItems,
 	}
 	h.pushUpdatesToOtherClients(ps.ByName("roomID"), userIDStr, info)
+	h.checkBudgets(roomID, items)
 
 	response := map[string]interface{}{
-		"newItems":        req.Items,
+		"newItems":        items,
 		"simplifiedItems": simplifiedItems,
 	}
 
@@ -245,19 +369,53 @@ func (h *Handler) GetSimplifiedItems(w http.ResponseWriter, r *http.Request, ps
 		return
 	}
 
-	var simplifiedItems []models.SimplifiedItem
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if !h.requireRoomMember(w, roomID, userID) {
+		return
+	}
 
-	if cachedSimplifiedItems, cacheFound := h.RoomToSimplifiedItems.Load(roomID); cacheFound {
-		simplifiedItems = cachedSimplifiedItems.([]models.SimplifiedItem)
-	} else {
-		computedSimplifiedItems, _ := h.simplifyAndStore(roomID, DefaultAlgo)
-		simplifiedItems = computedSimplifiedItems
+	// households settle as one party when asked for
+	if r.URL.Query().Get("group_by") == "household" {
+		h.writeHouseholdSettlements(w, r, roomID)
+		return
+	}
+
+	simplifiedItems := h.loadSimplifiedItems(roomID)
+
+	if r.URL.Query().Get("display_currency") == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(simplifiedItems)
+		return
+	}
+
+	// with a display currency the settlements come with the conversion used
+	var room models.Room
+	if err := h.DB.First(&room, "id = ?", roomID).Error; err != nil {
+		http.Error(w, "ROOM_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+	conversion, err := h.displayConversion(&room, r)
+	if err != nil {
+		writeError(w, err, "FX_PROVIDER_ERROR")
+		return
+	}
+	displayed, err := displaySimplifiedItems(simplifiedItems, conversion)
+	if err != nil {
+		writeError(w, err, "FX_CONVERSION_FAILED")
+		return
 	}
 
+	response := map[string]interface{}{
+		"simplifiedItems": displayed,
+		"conversion":      conversion,
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(simplifiedItems)
+	json.NewEncoder(w).Encode(response)
 }
 
+// SimplifyItems returns the room's settlement plan. With ?algo= it previews
+// the plan another algorithm would give, which is neither cached nor saved;
+// the room's algorithm is changed through its settings.
 func (h *Handler) SimplifyItems(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	roomID, err := uuid.Parse(ps.ByName("roomID"))
 	if err != nil {
@@ -265,29 +423,216 @@ func (h *Handler) SimplifyItems(w http.ResponseWriter, r *http.Request, ps httpr
 		return
 	}
 
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermAddItems); !ok {
+		return
+	}
+
 	algoStr := r.URL.Query().Get("algo")
-	algo := h.Simplifier.GetAlgorithmType(algoStr)
+	if algoStr != "" && !validSimplifyAlgo(algoStr) {
+		http.Error(w, "INVALID_ALGO", http.StatusBadRequest)
+		return
+	}
+
+	var room models.Room
+	if err := h.DB.First(&room, "id = ?", roomID).Error; err != nil {
+		http.Error(w, "ROOM_NOT_FOUND", http.StatusNotFound)
+		return
+	}
 
-	simplifiedItems, _ := h.simplifyAndStore(roomID, algo)
+	var simplifiedItems []models.SimplifiedItem
+	preview := algoStr != "" && h.Simplifier.GetAlgorithmType(algoStr) != h.algorithmOf(room)
+	if preview {
+		simplifiedItems, err = h.simplify(&room, h.Simplifier.GetAlgorithmType(algoStr))
+	} else {
+		simplifiedItems, err = h.resimplify(roomID)
+	}
+	if err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
 
 	response := map[string]interface{}{
 		"algo":            algoStr,
+		"preview":         preview,
 		"simplifiedItems": simplifiedItems,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *Handler) simplifyAndStore(roomID uuid.UUID, algoType algorithm.AlgoType) ([]models.SimplifiedItem, error) {
-	var items []models.Item
-	if err := h.DB.Where("room_id = ?", roomID).Order("created_at ASC").Find(&items).Error; err != nil {
+// loadSimplifiedItems returns the room's settlement plan, from the cache unless
+// the room floats with current exchange rates.
+func (h *Handler) loadSimplifiedItems(roomID uuid.UUID) []models.SimplifiedItem {
+	var fxMode string
+	h.DB.Model(&models.Room{}).Where("id = ?", roomID).Select("fx_mode").Scan(&fxMode)
+
+	if cachedSimplifiedItems, cacheFound := h.RoomToSimplifiedItems.Load(roomID); cacheFound && fxMode != FxModeFloating {
+		return cachedSimplifiedItems.([]models.SimplifiedItem)
+	}
+	simplifiedItems, _ := h.resimplify(roomID)
+	return simplifiedItems
+}
+
+// resimplify recomputes the settlement plan with the room's own algorithm.
+func (h *Handler) resimplify(roomID uuid.UUID) ([]models.SimplifiedItem, error) {
+	var room models.Room
+	if err := h.DB.Select("simplify_algo").First(&room, "id = ?", roomID).Error; err != nil {
 		return nil, err
 	}
+	return h.simplifyAndStore(roomID, h.algorithmOf(room))
+}
 
-	// TODO: SimplifiedItems have id of 0
+func (h *Handler) algorithmOf(room models.Room) algorithm.AlgoType {
+	if room.SimplifyAlgo == "" {
+		return DefaultAlgo
+	}
+	return h.Simplifier.GetAlgorithmType(room.SimplifyAlgo)
+}
+
+func (h *Handler) simplifyAndStore(roomID uuid.UUID, algoType algorithm.AlgoType) ([]models.SimplifiedItem, error) {
+	var room models.Room
+	if err := h.DB.First(&room, "id = ?", roomID).Error; err != nil {
+		return nil, err
+	}
+	items, err := h.approvedItems(&room)
+	if err != nil {
+		return nil, err
+	}
 	simplifiedItems := h.Simplifier.SimplifyItems(items, algoType)
+	householdItems, err := h.simplifyHouseholds(roomID, items, algoType, simplifiedItems)
+	if err != nil {
+		return nil, err
+	}
 
 	h.RoomToSimplifiedItems.Store(roomID, simplifiedItems)
+	h.RoomToHouseholdItems.Store(roomID, householdItems)
 
 	return simplifiedItems, nil
 }
+
+// simplify computes the room's settlement plan with algoType, without caching it.
+func (h *Handler) simplify(room *models.Room, algoType algorithm.AlgoType) ([]models.SimplifiedItem, error) {
+	items, err := h.approvedItems(room)
+	if err != nil {
+		return nil, err
+	}
+	// TODO: SimplifiedItems have id of 0
+	return h.Simplifier.SimplifyItems(items, algoType), nil
+}
+
+// approvedItems returns the items that count towards the room's balances, at
+// current rates if the room floats.
+func (h *Handler) approvedItems(room *models.Room) ([]models.Item, error) {
+	var items []models.Item
+	if err := h.DB.Where("room_id = ? AND status = ?", room.ID, ItemApproved).Order("occurred_at ASC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if room.FxMode == FxModeFloating {
+		items, _ = h.floatItems(room, items)
+	}
+	return items, nil
+}
+
+// prepareNewItems validates items about to be created in the room, fills in
+// their base currency amounts and applies the room's approval policy.
+func (h *Handler) prepareNewItems(roomID uuid.UUID, creatorID uuid.UUID, items []models.Item) error {
+	var room models.Room
+	if err := h.DB.First(&room, "id = ?", roomID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return &requestError{code: "ROOM_NOT_FOUND", status: http.StatusNotFound}
+		}
+		return err
+	}
+	if err := h.checkItemParties(roomID, items); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range items {
+		if items[i].OccurredAt.IsZero() {
+			items[i].OccurredAt = now
+		}
+		if err := checkOccurredAt(&room, items[i].OccurredAt, now); err != nil {
+			return err
+		}
+	}
+
+	if err := normalizeItemCurrencies(items); err != nil {
+		return &requestError{code: "INVALID_CURRENCY", status: http.StatusBadRequest}
+	}
+	if err := h.convertForeignItems(&room, items); err != nil {
+		return err
+	}
+	applyApprovalPolicy(&room, creatorID, items)
+	return nil
+}
+
+// checkItemParties rejects items whose payer or payee is neither a member of
+// the room nor one of its placeholders.
+func (h *Handler) checkItemParties(roomID uuid.UUID, items []models.Item) error {
+	seen := map[uuid.UUID]bool{}
+	var parties []uuid.UUID
+	for _, item := range items {
+		for _, id := range []uuid.UUID{item.FromUserID, item.ToUserID} {
+			if !seen[id] {
+				seen[id] = true
+				parties = append(parties, id)
+			}
+		}
+	}
+	if len(parties) == 0 {
+		return nil
+	}
+
+	members := h.DB.Model(&models.RoomUser{}).Select("user_id").Where("room_id = ? AND status = ?", roomID, MemberIn)
+	var known int64
+	if err := h.DB.Model(&models.User{}).
+		Where("id IN ?", parties).
+		Where("placeholder_room_id = ? OR id IN (?)", roomID, members).
+		Count(&known).Error; err != nil {
+		return err
+	}
+	if known != int64(len(parties)) {
+		return &requestError{code: "USER_NOT_IN_ROOM", status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+// checkOccurredAt rejects dates in the future and dates before the day the
+// room was created. The whole day counts, as imported dates carry no time.
+func checkOccurredAt(room *models.Room, occurredAt time.Time, now time.Time) error {
+	created := room.CreatedAt.UTC().Truncate(24 * time.Hour)
+	if occurredAt.After(now.Add(MaxOccurredAtSkew)) || occurredAt.Before(created) {
+		return &requestError{code: "INVALID_OCCURRED_AT", status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+// applyApprovalPolicy stamps new items with their creator and, in rooms that
+// require approval, marks items charging anyone but the creator as pending.
+func applyApprovalPolicy(room *models.Room, creatorID uuid.UUID, items []models.Item) {
+	for i := range items {
+		items[i].CreatorID = creatorID
+		items[i].Status = ItemApproved
+		items[i].DisputeReason = ""
+		if room.RequireItemApproval && items[i].FromUserID != creatorID && items[i].FromUserID != items[i].ToUserID {
+			items[i].Status = ItemPending
+		}
+	}
+}
+
+// normalizeItemCurrencies upper-cases foreign currency codes and rejects any
+// that are not ISO 4217.
+func normalizeItemCurrencies(items []models.Item) error {
+	for i := range items {
+		if items[i].ForeignCurrency == "" {
+			continue
+		}
+		items[i].ForeignCurrency = strings.ToUpper(items[i].ForeignCurrency)
+		if err := models.ValidateCurrency(items[i].ForeignCurrency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
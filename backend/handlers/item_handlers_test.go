@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"backend/models"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckOccurredAt(t *testing.T) {
+	room := models.Room{CreatedAt: time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)}
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		occurredAt time.Time
+		valid      bool
+	}{
+		{now, true},
+		{now.Add(MaxOccurredAtSkew), true},
+		{now.Add(MaxOccurredAtSkew + time.Second), false},
+		{room.CreatedAt, true},
+		// the day the room was created counts, as imported dates carry no time
+		{time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), true},
+		{time.Date(2024, 3, 9, 23, 59, 0, 0, time.UTC), false},
+		{time.Time{}, false},
+	} {
+		err := checkOccurredAt(&room, tc.occurredAt, now)
+		if tc.valid {
+			assert.NoError(t, err, tc.occurredAt)
+		} else {
+			assert.EqualError(t, err, "INVALID_OCCURRED_AT", tc.occurredAt)
+		}
+	}
+}
+
+func TestCheckItemParties(t *testing.T) {
+	// the fake database knows exactly one of the parties asked about
+	h := &Handler{DB: memberDB(t, RoleMember, "placeholder_room_id")}
+	member := uuid.New()
+
+	assert.NoError(t, h.checkItemParties(uuid.New(), []models.Item{{FromUserID: member, ToUserID: member}}))
+	assert.NoError(t, h.checkItemParties(uuid.New(), nil))
+
+	err := h.checkItemParties(uuid.New(), []models.Item{{FromUserID: member, ToUserID: uuid.New()}})
+	var reqErr *requestError
+	if assert.True(t, errors.As(err, &reqErr)) {
+		assert.Equal(t, "USER_NOT_IN_ROOM", reqErr.code)
+		assert.Equal(t, http.StatusBadRequest, reqErr.status)
+	}
+}
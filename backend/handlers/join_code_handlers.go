@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+type JoinCodeRequest struct {
+	// ExpiresInHours of 0 keeps the code valid until it is regenerated; longer
+	// than MaxInviteLifetime is capped.
+	ExpiresInHours int `json:"expires_in_hours"`
+}
+
+type JoinCodeResponse struct {
+	Code      string     `json:"code"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (h *Handler) GetJoinCode(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermManageMembers)
+	if !ok {
+		return
+	}
+	if room.JoinCode == nil {
+		http.Error(w, "JOIN_CODE_NOT_SET", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JoinCodeResponse{Code: models.FormatJoinCode(*room.JoinCode), ExpiresAt: room.JoinCodeExpiresAt})
+}
+
+// RegenerateJoinCode gives the room a new join code, replacing any previous
+// one.
+func (h *Handler) RegenerateJoinCode(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermManageMembers)
+	if !ok {
+		return
+	}
+
+	var req JoinCodeRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+	lifetime, err := inviteLifetime(req.ExpiresInHours)
+	if err != nil {
+		writeError(w, err, "INVALID_EXPIRY")
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInHours > 0 {
+		t := time.Now().Add(lifetime)
+		expiresAt = &t
+	}
+
+	code, err := models.NewUniqueJoinCode(h.DB)
+	if err != nil {
+		http.Error(w, "JOIN_CODE_ERROR", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.DB.Model(&room).Updates(map[string]interface{}{"join_code": code, "join_code_expires_at": expiresAt}).Error; err != nil {
+		http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JoinCodeResponse{Code: models.FormatJoinCode(code), ExpiresAt: expiresAt})
+}
+
+func (h *Handler) DeleteJoinCode(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermManageMembers)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.Model(&room).Updates(map[string]interface{}{"join_code": nil, "join_code_expires_at": nil}).Error; err != nil {
+		http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "JOIN_CODE_DELETED"})
+}
+
+// JoinRoomByCode joins the room whose code is given, in any case and with or
+// without the dash. It stands in for an invite, so the room's approval rules
+// apply as usual.
+func (h *Handler) JoinRoomByCode(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	code, err := models.NormalizeJoinCode(ps.ByName("code"))
+	if err != nil {
+		http.Error(w, "INVALID_JOIN_CODE", http.StatusNotFound)
+		return
+	}
+
+	var room models.Room
+	if err := h.DB.First(&room, "join_code = ?", code).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "INVALID_JOIN_CODE", http.StatusNotFound)
+		} else {
+			http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		}
+		return
+	}
+	if room.JoinCodeExpiresAt != nil && time.Now().After(*room.JoinCodeExpiresAt) {
+		http.Error(w, "JOIN_CODE_EXPIRED", http.StatusGone)
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Where("id = ?", r.Context().Value("userID")).First(&user).Error; err != nil {
+		http.Error(w, "USER_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+
+	var existing models.RoomUser
+	err = h.DB.Where("room_id = ? AND user_id = ?", room.ID, user.ID).First(&existing).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		return
+	}
+	found := err == nil
+	if found && (existing.Status == MemberIn || existing.Status == MemberPending) {
+		h.writeJoinResponse(w, room, existing.Status)
+		return
+	}
+	if room.ArchivedAt != nil {
+		http.Error(w, "ROOM_ARCHIVED", http.StatusConflict)
+		return
+	}
+
+	h.admitMember(w, room, user, existing, found)
+}
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const MaxImportRows = 5000
+
+// formulaPrefixes start cells that spreadsheet apps treat as formulas.
+const formulaPrefixes = "=+-@\t\r"
+
+// ledgerColumns is the column order of exports and the default import mapping.
+var ledgerColumns = []string{
+	"id", "group", "date", "type", "from_user", "to_user",
+	"amount", "currency", "foreign_amount", "foreign_currency", "content", "category", "status",
+}
+
+type LedgerRow struct {
+	ID              uuid.UUID `json:"id"`
+	Group           uuid.UUID `json:"group"`
+	Date            time.Time `json:"date"`
+	Type            string    `json:"type"`
+	FromUser        string    `json:"from_user"`
+	ToUser          string    `json:"to_user"`
+	Amount          int64     `json:"amount"`
+	Currency        string    `json:"currency"`
+	ForeignAmount   int64     `json:"foreign_amount"`
+	ForeignCurrency string    `json:"foreign_currency"`
+	Content         string    `json:"content"`
+	Category        string    `json:"category"`
+	Status          string    `json:"status"`
+}
+
+type ImportLedgerRequest struct {
+	Format string `json:"format"`
+	// Data is CSV text (with a header row) or a JSON array of objects.
+	Data string `json:"data"`
+	// Mapping maps source column names to ledger columns. Columns that are
+	// not mapped are matched by name; unknown columns are ignored.
+	Mapping map[string]string `json:"mapping"`
+	DryRun  bool              `json:"dry_run"`
+}
+
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column"`
+	Message string `json:"message"`
+}
+
+func (h *Handler) ExportLedger(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if !h.requireRoomMember(w, roomID, userID) {
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, "INVALID_FORMAT", http.StatusBadRequest)
+		return
+	}
+
+	var room models.Room
+	if err := h.DB.First(&room, "id = ?", roomID).Error; err != nil {
+		http.Error(w, "ROOM_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+
+	userNames, err := h.roomUserNames(roomID)
+	if err != nil {
+		http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		return
+	}
+
+	var items []models.Item
+	if err := h.DB.Where("room_id = ?", roomID).Order("occurred_at ASC, id ASC").Find(&items).Error; err != nil {
+		http.Error(w, "DB_ERROR_ROOM_ITEMS", http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([]LedgerRow, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, LedgerRow{
+			ID:              item.ID,
+			Group:           item.GroupID,
+			Date:            item.OccurredAt,
+			Type:            item.TransactionType,
+			FromUser:        userNames[item.FromUserID],
+			ToUser:          userNames[item.ToUserID],
+			Amount:          item.Amount,
+			Currency:        room.BaseCurrency,
+			ForeignAmount:   item.ForeignAmount,
+			ForeignCurrency: item.ForeignCurrency,
+			Content:         item.Content,
+			Category:        item.Category,
+			Status:          item.Status,
+		})
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", room.Name, time.Now().Format(time.DateOnly), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(filename)))
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rows)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.Write(ledgerColumns)
+	for _, row := range rows {
+		cw.Write([]string{
+			row.ID.String(),
+			row.Group.String(),
+			row.Date.UTC().Format(time.RFC3339),
+			row.Type,
+			csvCell(row.FromUser),
+			csvCell(row.ToUser),
+			strconv.FormatInt(row.Amount, 10),
+			row.Currency,
+			strconv.FormatInt(row.ForeignAmount, 10),
+			row.ForeignCurrency,
+			csvCell(row.Content),
+			csvCell(row.Category),
+			row.Status,
+		})
+	}
+	cw.Flush()
+}
+
+func (h *Handler) ImportLedger(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermAddItems); !ok {
+		return
+	}
+
+	var req ImportLedgerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+
+	records, err := parseImportRecords(req.Format, req.Data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(records) > MaxImportRows {
+		http.Error(w, "TOO_MANY_ROWS", http.StatusBadRequest)
+		return
+	}
+
+	userNames, err := h.roomUserNames(roomID)
+	if err != nil {
+		http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		return
+	}
+	userIDs := make(map[string]uuid.UUID, len(userNames))
+	for id, name := range userNames {
+		userIDs[strings.ToLower(name)] = id
+	}
+
+	items, rowErrors := buildImportItems(roomID, records, req.Mapping, userIDs)
+	if len(rowErrors) == 0 {
+		if err := h.prepareNewItems(roomID, userID, items); err != nil {
+			writeError(w, err, "DB_ERROR_ITEMS")
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"dryRun": req.DryRun,
+		"rows":   len(records),
+		"errors": rowErrors,
+	}
+
+	if len(rowErrors) > 0 || req.DryRun {
+		if len(rowErrors) == 0 {
+			response["items"] = items
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if len(rowErrors) > 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if len(items) > 0 {
+		if err := h.DB.Transaction(func(tx *gorm.DB) error {
+			return tx.CreateInBatches(&items, 500).Error
+		}); err != nil {
+			http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	simplifiedItems, _ := h.resimplify(roomID)
+	if len(items) > 0 {
+		h.recordActivity(roomID, userID, ActivityItemsCreated, nil, items)
+	}
+
+	h.pushUpdatesToOtherClients(roomID.String(), userID.String(), &SSEUpdateInfo{
+		NewItems:        items,
+		SimplifiedItems: simplifiedItems,
+	})
+	h.checkBudgets(roomID, items)
+
+	response["newItems"] = items
+	response["simplifiedItems"] = simplifiedItems
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// csvCell keeps text that spreadsheet apps would run as a formula from being
+// one, by prefixing it with a quote.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// uncsvCell undoes csvCell for a cell read back from an export.
+func uncsvCell(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// roomUserNames returns the names of everyone who has ever been in the room,
+// including members who have since left, keyed by user ID.
+func (h *Handler) roomUserNames(roomID uuid.UUID) (map[uuid.UUID]string, error) {
+	var users []models.User
+	if err := h.DB.Table("room_users").
+		Select("users.id, users.name").
+		Joins("JOIN users ON users.id = room_users.user_id").
+		Where("room_users.room_id = ?", roomID).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	names := make(map[uuid.UUID]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+	return names, nil
+}
+
+// parseImportRecords turns CSV or JSON input into one column->value map per row.
+func parseImportRecords(format string, data string) ([]map[string]string, error) {
+	switch strings.ToLower(format) {
+	case "", "csv":
+		cr := csv.NewReader(strings.NewReader(data))
+		cr.FieldsPerRecord = -1
+		rows, err := cr.ReadAll()
+		if err != nil {
+			return nil, errors.New("INVALID_CSV")
+		}
+		if len(rows) == 0 {
+			return nil, errors.New("MISSING_CSV_HEADER")
+		}
+
+		header := rows[0]
+		records := make([]map[string]string, 0, len(rows)-1)
+		for _, row := range rows[1:] {
+			record := map[string]string{}
+			for i, column := range header {
+				if i < len(row) {
+					record[strings.TrimSpace(column)] = uncsvCell(strings.TrimSpace(row[i]))
+				}
+			}
+			records = append(records, record)
+		}
+		return records, nil
+
+	case "json":
+		var objects []map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&objects); err != nil {
+			return nil, errors.New("INVALID_JSON")
+		}
+
+		records := make([]map[string]string, 0, len(objects))
+		for _, object := range objects {
+			record := map[string]string{}
+			for column, value := range object {
+				if value != nil {
+					record[column] = strings.TrimSpace(fmt.Sprint(value))
+				}
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+
+	return nil, errors.New("INVALID_FORMAT")
+}
+
+// buildImportItems validates every record and converts it into an Item. Rows
+// that share a group label are given the same GroupID.
+func buildImportItems(roomID uuid.UUID, records []map[string]string, mapping map[string]string, userIDs map[string]uuid.UUID) ([]models.Item, []ImportRowError) {
+	items := []models.Item{}
+	rowErrors := []ImportRowError{}
+	groupIDs := map[string]uuid.UUID{}
+
+	for i, record := range records {
+		row := i + 1
+		fields := map[string]string{}
+		for column, value := range record {
+			if target, ok := mapping[column]; ok {
+				fields[target] = value
+			} else if _, mapped := fields[column]; !mapped {
+				fields[column] = value
+			}
+		}
+
+		fail := func(column string, message string) {
+			rowErrors = append(rowErrors, ImportRowError{Row: row, Column: column, Message: message})
+		}
+		errorCount := len(rowErrors)
+
+		item := models.Item{RoomID: roomID, Content: fields["content"], Category: fields["category"]}
+
+		item.TransactionType = strings.ToUpper(fields["type"])
+		if item.TransactionType == "" {
+			item.TransactionType = Expense
+		}
+		if item.TransactionType != Expense && item.TransactionType != Income && item.TransactionType != Transfer {
+			fail("type", "UNKNOWN_TRANSACTION_TYPE")
+		}
+
+		for _, column := range []string{"from_user", "to_user"} {
+			name := fields[column]
+			if name == "" {
+				fail(column, "MISSING_USER")
+				continue
+			}
+			id, ok := userIDs[strings.ToLower(name)]
+			if !ok {
+				fail(column, "USER_NOT_IN_ROOM")
+				continue
+			}
+			if column == "from_user" {
+				item.FromUserID = id
+			} else {
+				item.ToUserID = id
+			}
+		}
+
+		if amount, err := strconv.ParseInt(fields["amount"], 10, 64); err != nil {
+			fail("amount", "INVALID_AMOUNT")
+		} else if amount <= 0 {
+			fail("amount", "NON_POSITIVE_AMOUNT")
+		} else {
+			item.Amount = amount
+		}
+
+		if s := fields["foreign_amount"]; s != "" {
+			if amount, err := strconv.ParseInt(s, 10, 64); err != nil || amount < 0 {
+				fail("foreign_amount", "INVALID_AMOUNT")
+			} else {
+				item.ForeignAmount = amount
+			}
+		}
+		item.ForeignCurrency = strings.ToUpper(fields["foreign_currency"])
+		if item.ForeignAmount != 0 && item.ForeignCurrency == "" {
+			fail("foreign_currency", "MISSING_CURRENCY")
+		} else if item.ForeignCurrency != "" && models.ValidateCurrency(item.ForeignCurrency) != nil {
+			fail("foreign_currency", "UNKNOWN_CURRENCY")
+		}
+
+		if s := fields["date"]; s != "" {
+			date, _, err := parseDateParam(s)
+			if err != nil {
+				fail("date", "INVALID_DATE")
+			}
+			item.OccurredAt = date
+		}
+
+		if len(rowErrors) > errorCount {
+			continue
+		}
+
+		label := fields["group"]
+		if label == "" {
+			item.GroupID = uuid.New()
+		} else {
+			if _, ok := groupIDs[label]; !ok {
+				groupIDs[label] = uuid.New()
+			}
+			item.GroupID = groupIDs[label]
+		}
+		items = append(items, item)
+	}
+
+	return items, rowErrors
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVCell(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                 "",
+		"Pizza":            "Pizza",
+		"=SUM(A1:A9)":      "'=SUM(A1:A9)",
+		"+1":               "'+1",
+		"-1":               "'-1",
+		"@cmd":             "'@cmd",
+		"\t=1":             "'\t=1",
+		"it's = fine":      "it's = fine",
+		"'already quoted'": "'already quoted'",
+	} {
+		assert.Equal(t, want, csvCell(in), in)
+		assert.Equal(t, in, uncsvCell(csvCell(in)), in)
+	}
+}
+
+func TestParseImportRecords_CSV(t *testing.T) {
+	records, err := parseImportRecords("csv", "from_user, amount ,content\nalice,100,'=1+1\nbob\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"from_user": "alice", "amount": "100", "content": "=1+1"},
+		{"from_user": "bob"},
+	}, records)
+}
+
+func TestParseImportRecords_Malformed(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		data   string
+		want   string
+	}{
+		{"csv", "", "MISSING_CSV_HEADER"},
+		{"csv", "a,b\n\"unterminated,1\n", "INVALID_CSV"},
+		{"json", "{\"not\": \"an array\"}", "INVALID_JSON"},
+		{"json", "[{\"amount\": 1}", "INVALID_JSON"},
+		{"xml", "<ledger/>", "INVALID_FORMAT"},
+	} {
+		_, err := parseImportRecords(tc.format, tc.data)
+		assert.EqualError(t, err, tc.want, tc.data)
+	}
+}
+
+func TestBuildImportItems(t *testing.T) {
+	roomID, alice, bob := uuid.New(), uuid.New(), uuid.New()
+	userIDs := map[string]uuid.UUID{"alice": alice, "bob": bob}
+
+	records := []map[string]string{
+		{"payer": "Alice", "to_user": "bob", "amount": "500", "group": "dinner", "date": "2024-03-01"},
+		{"payer": "alice", "to_user": "bob", "amount": "300", "group": "dinner", "type": "income"},
+	}
+	items, rowErrors := buildImportItems(roomID, records, map[string]string{"payer": "from_user"}, userIDs)
+	assert.Empty(t, rowErrors)
+	assert.Len(t, items, 2)
+	assert.Equal(t, alice, items[0].FromUserID)
+	assert.Equal(t, bob, items[0].ToUserID)
+	assert.Equal(t, int64(500), items[0].Amount)
+	assert.Equal(t, Expense, items[0].TransactionType)
+	assert.Equal(t, Income, items[1].TransactionType)
+	assert.Equal(t, items[0].GroupID, items[1].GroupID)
+	assert.Equal(t, 2024, items[0].OccurredAt.Year())
+}
+
+func TestBuildImportItems_RowErrors(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	userIDs := map[string]uuid.UUID{"alice": alice, "bob": bob}
+	valid := func(overrides map[string]string) map[string]string {
+		record := map[string]string{"from_user": "alice", "to_user": "bob", "amount": "100"}
+		for k, v := range overrides {
+			record[k] = v
+		}
+		return record
+	}
+
+	for _, tc := range []struct {
+		record map[string]string
+		want   ImportRowError
+	}{
+		{valid(map[string]string{"amount": "12.50"}), ImportRowError{Row: 1, Column: "amount", Message: "INVALID_AMOUNT"}},
+		{valid(map[string]string{"amount": "abc"}), ImportRowError{Row: 1, Column: "amount", Message: "INVALID_AMOUNT"}},
+		{valid(map[string]string{"amount": "99999999999999999999"}), ImportRowError{Row: 1, Column: "amount", Message: "INVALID_AMOUNT"}},
+		{valid(map[string]string{"amount": "0"}), ImportRowError{Row: 1, Column: "amount", Message: "NON_POSITIVE_AMOUNT"}},
+		{valid(map[string]string{"amount": "-5"}), ImportRowError{Row: 1, Column: "amount", Message: "NON_POSITIVE_AMOUNT"}},
+		{valid(map[string]string{"from_user": "mallory"}), ImportRowError{Row: 1, Column: "from_user", Message: "USER_NOT_IN_ROOM"}},
+		{valid(map[string]string{"to_user": ""}), ImportRowError{Row: 1, Column: "to_user", Message: "MISSING_USER"}},
+		{valid(map[string]string{"type": "gift"}), ImportRowError{Row: 1, Column: "type", Message: "UNKNOWN_TRANSACTION_TYPE"}},
+		{valid(map[string]string{"foreign_amount": "-1", "foreign_currency": "EUR"}), ImportRowError{Row: 1, Column: "foreign_amount", Message: "INVALID_AMOUNT"}},
+		{valid(map[string]string{"foreign_amount": "100"}), ImportRowError{Row: 1, Column: "foreign_currency", Message: "MISSING_CURRENCY"}},
+		{valid(map[string]string{"foreign_currency": "XYZ"}), ImportRowError{Row: 1, Column: "foreign_currency", Message: "UNKNOWN_CURRENCY"}},
+		{valid(map[string]string{"date": "01/03/2024"}), ImportRowError{Row: 1, Column: "date", Message: "INVALID_DATE"}},
+	} {
+		items, rowErrors := buildImportItems(uuid.New(), []map[string]string{tc.record}, nil, userIDs)
+		assert.Empty(t, items, tc.record)
+		assert.Equal(t, []ImportRowError{tc.want}, rowErrors, tc.record)
+	}
+}
+
+func TestBuildImportItems_ReportsEveryBadRow(t *testing.T) {
+	userIDs := map[string]uuid.UUID{"alice": uuid.New(), "bob": uuid.New()}
+	records := []map[string]string{
+		{"from_user": "alice", "to_user": "bob", "amount": "100"},
+		{"from_user": "carol", "to_user": "bob", "amount": "x"},
+	}
+
+	items, rowErrors := buildImportItems(uuid.New(), records, nil, userIDs)
+	assert.Len(t, items, 1)
+	assert.Equal(t, []ImportRowError{
+		{Row: 2, Column: "from_user", Message: "USER_NOT_IN_ROOM"},
+		{Row: 2, Column: "amount", Message: "INVALID_AMOUNT"},
+	}, rowErrors)
+}
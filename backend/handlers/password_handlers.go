@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"backend/mail"
+	"backend/middleware"
+	"backend/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	MinPasswordLength     = 6
+	PasswordResetLifetime = time.Hour
+	// PasswordResetsPerIP and PasswordResetsPerEmail limit reset requests
+	// within PasswordResetWindow.
+	PasswordResetsPerIP    = 10
+	PasswordResetsPerEmail = 3
+	PasswordResetWindow    = time.Hour
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type ConfirmPasswordResetRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type UpdateEmailRequest struct {
+	// Email of "" removes the address.
+	Email string `json:"email"`
+}
+
+// ChangePassword sets a new password for the caller, who has to give the
+// current one. Their other sessions are logged out.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID := r.Context().Value("userID").(uuid.UUID)
+	sessionID := r.Context().Value("sessionID").(uuid.UUID)
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_REQUEST", http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < MinPasswordLength {
+		http.Error(w, "INVALID_PW_LENGTH", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, "id = ?", userID).Error; err != nil {
+		http.Error(w, "USER_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		http.Error(w, "INCORRECT_PW", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setPassword(tx, user.ID, req.NewPassword); err != nil {
+			return err
+		}
+		_, err := h.revokeSessions(tx.Where("user_id = ? AND id != ?", user.ID, sessionID))
+		return err
+	}); err != nil {
+		http.Error(w, "ERROR_DB_USERS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "PASSWORD_CHANGED"})
+}
+
+// RequestPasswordReset mails a reset token to the account with the given
+// address. It answers the same, and as fast, whether or not there is one, so
+// that it cannot be used to find out who has an account: the account is
+// looked up and mailed after answering. Requests are limited per client and
+// per address.
+func (h *Handler) RequestPasswordReset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if !h.ResetIPLimiter.Allow(clientIP(r)) {
+		http.Error(w, "TOO_MANY_REQUESTS", http.StatusTooManyRequests)
+		return
+	}
+
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_REQUEST", http.StatusBadRequest)
+		return
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil || email == nil {
+		http.Error(w, "INVALID_EMAIL", http.StatusBadRequest)
+		return
+	}
+	if !h.ResetEmailLimiter.Allow(*email) {
+		http.Error(w, "TOO_MANY_REQUESTS", http.StatusTooManyRequests)
+		return
+	}
+
+	go h.sendPasswordReset(*email)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "PASSWORD_RESET_REQUESTED"})
+}
+
+// sendPasswordReset mails a new reset token to the account with email, if
+// there is one. Failures are only logged, as the request was answered already.
+func (h *Handler) sendPasswordReset(email string) {
+	var user models.User
+	if err := h.DB.First(&user, "email = ? AND placeholder_room_id IS NULL", email).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("failed to look up account for password reset: %v", err)
+		}
+		return
+	}
+
+	token, hash, err := middleware.NewOpaqueToken()
+	if err != nil {
+		log.Printf("failed to generate password reset token for user %s: %v", user.ID, err)
+		return
+	}
+	// a new request leaves only the latest token usable
+	reset := models.PasswordReset{UserID: user.ID, TokenHash: hash, ExpiresAt: time.Now().Add(PasswordResetLifetime)}
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.PasswordReset{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&reset).Error
+	}); err != nil {
+		log.Printf("failed to store password reset for user %s: %v", user.ID, err)
+		return
+	}
+
+	if err := h.Mailer.Send(passwordResetMessage(email, user.Name, h.passwordResetLink(token))); err != nil {
+		log.Printf("failed to send password reset to user %s: %v", user.ID, err)
+	}
+}
+
+// ConfirmPasswordReset sets a new password with a reset token, which can only
+// be used once. Every session of the account is logged out.
+func (h *Handler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var req ConfirmPasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "INVALID_REQUEST", http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < MinPasswordLength {
+		http.Error(w, "INVALID_PW_LENGTH", http.StatusBadRequest)
+		return
+	}
+
+	var reset models.PasswordReset
+	if err := h.DB.First(&reset, "token_hash = ?", middleware.HashOpaqueToken(req.Token)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "INVALID_RESET_TOKEN", http.StatusUnauthorized)
+		} else {
+			http.Error(w, "DB_ERROR_PASSWORD_RESETS", http.StatusInternalServerError)
+		}
+		return
+	}
+	now := time.Now()
+	if reset.UsedAt != nil || now.After(reset.ExpiresAt) {
+		http.Error(w, "INVALID_RESET_TOKEN", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// the guard keeps a token used twice at once from working twice
+		result := tx.Model(&models.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return &requestError{code: "INVALID_RESET_TOKEN", status: http.StatusUnauthorized}
+		}
+		if err := setPassword(tx, reset.UserID, req.NewPassword); err != nil {
+			return err
+		}
+		_, err := h.revokeSessions(tx.Where("user_id = ?", reset.UserID))
+		return err
+	})
+	if err != nil {
+		writeError(w, err, "ERROR_DB_USERS")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "PASSWORD_RESET"})
+}
+
+// GetAccount returns the caller's own account, including the email address
+// that is left out wherever users are shown to others.
+func (h *Handler) GetAccount(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID := r.Context().Value("userID").(uuid.UUID)
+
+	var user models.User
+	if err := h.DB.First(&user, "id = ?", userID).Error; err != nil {
+		http.Error(w, "USER_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"id":    user.ID,
+		"name":  user.Name,
+		"email": user.Email,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateEmail sets or removes the caller's email address.
+func (h *Handler) UpdateEmail(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID := r.Context().Value("userID").(uuid.UUID)
+
+	var req UpdateEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_REQUEST", http.StatusBadRequest)
+		return
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		http.Error(w, "INVALID_EMAIL", http.StatusBadRequest)
+		return
+	}
+	if taken, err := h.emailTaken(email, userID); err != nil {
+		http.Error(w, "ERROR_DB_USERS", http.StatusInternalServerError)
+		return
+	} else if taken {
+		http.Error(w, "EMAIL_ALREADY_EXIST", http.StatusConflict)
+		return
+	}
+
+	if err := h.DB.Model(&models.User{}).Where("id = ?", userID).Update("email", email).Error; err != nil {
+		http.Error(w, "ERROR_DB_USERS", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"message": "EMAIL_UPDATED",
+		"email":   email,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// emailTaken tells whether another account than userID uses email.
+func (h *Handler) emailTaken(email *string, userID uuid.UUID) (bool, error) {
+	if email == nil {
+		return false, nil
+	}
+	var taken int64
+	err := h.DB.Model(&models.User{}).Where("email = ? AND id != ?", *email, userID).Count(&taken).Error
+	return taken > 0, err
+}
+
+func (h *Handler) passwordResetLink(token string) string {
+	if h.PasswordResetURL == "" {
+		return token
+	}
+	return h.PasswordResetURL + "?token=" + token
+}
+
+func setPassword(db *gorm.DB, userID uuid.UUID, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.User{}).Where("id = ?", userID).Update("password_hash", string(hashedPassword)).Error
+}
+
+// normalizeEmail validates an address as typed and lowercases it. An empty
+// address gives nil.
+func normalizeEmail(s string) (*string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	addr, err := netmail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return nil, fmt.Errorf("invalid email %q", s)
+	}
+	email := strings.ToLower(addr.Address)
+	return &email, nil
+}
+
+func passwordResetMessage(to string, name string, link string) mail.Message {
+	return mail.Message{
+		To:      to,
+		Subject: "Reset your BuyTogether password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse this to set a new password within the next hour:\n\n%s\n\n"+
+			"If you did not ask for a reset, you can ignore this email.\n", name, link),
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const PlaceholderClaimedEvent = "placeholder_claimed"
+
+type CreatePlaceholderRequest struct {
+	Name string `json:"name"`
+}
+
+// PlaceholderClaim tells clients that everything recorded for PlaceholderID
+// now belongs to UserID.
+type PlaceholderClaim struct {
+	PlaceholderID uuid.UUID `json:"placeholder_id"`
+	UserID        uuid.UUID `json:"user_id"`
+}
+
+// CreatePlaceholder adds a member without an account, who can be used in
+// items like anyone else until a real user claims them.
+func (h *Handler) CreatePlaceholder(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermAddItems); !ok {
+		return
+	}
+
+	var req CreatePlaceholderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if len(name) < 1 || len(name) > 20 {
+		http.Error(w, "INVALID_NAME_LENGTH", http.StatusBadRequest)
+		return
+	}
+
+	var taken int64
+	if err := h.DB.Table("room_users").
+		Joins("JOIN users ON users.id = room_users.user_id").
+		Where("room_users.room_id = ? AND room_users.status = ? AND users.name = ?", roomID, MemberIn, name).
+		Count(&taken).Error; err != nil {
+		http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		return
+	}
+	if taken > 0 {
+		http.Error(w, "NAME_TAKEN", http.StatusConflict)
+		return
+	}
+
+	// placeholders never act, so they get no rights of their own
+	placeholder := models.User{Name: name, PlaceholderRoomID: &roomID}
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&placeholder).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.RoomUser{RoomID: roomID, UserID: placeholder.ID, Status: MemberIn, Role: RoleViewer}).Error
+	}); err != nil {
+		http.Error(w, "DB_ERROR_USERS", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordActivity(roomID, userID, ActivityPlaceholderAdded, nil, placeholder)
+	h.pushUpdatesToOtherClients(roomID.String(), userID.String(), &SSEUpdateInfo{NewUser: &placeholder})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(placeholder)
+}
+
+// claimPlaceholder redeems a placeholder invite for user: every item and
+// record of the placeholder moves to user, who becomes a member of the
+// placeholder's household without needing approval, and the placeholder is
+// removed.
+func (h *Handler) claimPlaceholder(w http.ResponseWriter, room models.Room, user models.User, token string, placeholderID uuid.UUID) {
+	var roomUser models.RoomUser
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.redeemInvite(tx, room.ID, token); err != nil {
+			return err
+		}
+
+		var placeholder models.User
+		if err := tx.First(&placeholder, "id = ? AND placeholder_room_id = ?", placeholderID, room.ID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return &requestError{code: "INVALID_INVITE", status: http.StatusForbidden}
+			}
+			return err
+		}
+
+		for _, move := range []struct {
+			model  interface{}
+			column string
+		}{
+			{&models.Item{}, "from_user_id"},
+			{&models.Item{}, "to_user_id"},
+			{&models.Item{}, "creator_id"},
+			{&models.Budget{}, "user_id"},
+			{&models.Attachment{}, "uploader_id"},
+		} {
+			if err := tx.Model(move.model).
+				Where("room_id = ? AND "+move.column+" = ?", room.ID, placeholderID).
+				Update(move.column, user.ID).Error; err != nil {
+				return err
+			}
+		}
+		snapshotIDs := tx.Model(&models.RoomSnapshot{}).Select("id").Where("room_id = ?", room.ID)
+		for _, move := range []struct {
+			model  interface{}
+			column string
+		}{
+			{&models.SnapshotBalance{}, "user_id"},
+			{&models.SnapshotSettlement{}, "from_user_id"},
+			{&models.SnapshotSettlement{}, "to_user_id"},
+		} {
+			if err := tx.Model(move.model).
+				Where("room_snapshot_id IN (?) AND "+move.column+" = ?", snapshotIDs, placeholderID).
+				Update(move.column, user.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		var placeholderMember models.RoomUser
+		if err := tx.Where("room_id = ? AND user_id = ?", room.ID, placeholderID).First(&placeholderMember).Error; err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		// claiming rejoins a user who had left, keeping their former role
+		err := tx.Where("room_id = ? AND user_id = ?", room.ID, user.ID).First(&roomUser).Error
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			roomUser = models.RoomUser{RoomID: room.ID, UserID: user.ID, Status: MemberIn, Role: RoleMember, HouseholdID: placeholderMember.HouseholdID}
+			err = tx.Create(&roomUser).Error
+		case err == nil:
+			roomUser.Status = MemberIn
+			if placeholderMember.HouseholdID != nil {
+				roomUser.HouseholdID = placeholderMember.HouseholdID
+			}
+			err = tx.Model(&models.RoomUser{}).
+				Where("room_id = ? AND user_id = ?", room.ID, user.ID).
+				Updates(map[string]interface{}{"status": MemberIn, "household_id": roomUser.HouseholdID}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("room_id = ? AND user_id = ?", room.ID, placeholderID).Delete(&models.RoomUser{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Invite{}).Where("placeholder_id = ?", placeholderID).Update("placeholder_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&placeholder).Error
+	})
+	if err != nil {
+		writeError(w, err, "DB_ERROR_ROOMUSERS")
+		return
+	}
+
+	simplifiedItems, _ := h.resimplify(room.ID)
+	claim := PlaceholderClaim{PlaceholderID: placeholderID, UserID: user.ID}
+	h.recordActivity(room.ID, user.ID, ActivityPlaceholderClaimed, nil, claim)
+	h.pushUpdatesToOtherClients(room.ID.String(), user.ID.String(), &SSEUpdateInfo{
+		Event:           PlaceholderClaimedEvent,
+		NewUser:         &user,
+		Member:          &roomUser,
+		Claim:           &claim,
+		SimplifiedItems: simplifiedItems,
+	})
+
+	h.writeJoinResponse(w, room, MemberIn)
+}
@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"backend/fx"
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const (
+	RateDateOriginal = "ORIGINAL"
+	RateDateToday    = "TODAY"
+)
+
+const RevaluationEvent = "revaluation"
+
+type ChangeBaseCurrencyRequest struct {
+	Currency string `json:"currency"`
+	// RateDate picks the rate each item is revalued at: the one in effect when
+	// the item occurred (ORIGINAL) or today's (TODAY).
+	RateDate string `json:"rate_date"`
+}
+
+// ChangeBaseCurrency switches the room to a new base currency and revalues
+// every item into it. Each item is converted from the currency it was
+// originally entered in, which is kept as its foreign amount. Budgets and
+// their alerts are converted at today's rate, whichever rate date items use.
+func (h *Handler) ChangeBaseCurrency(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermChangeSettings)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value("userID").(uuid.UUID)
+
+	var req ChangeBaseCurrencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if err := models.ValidateCurrency(currency); err != nil {
+		http.Error(w, "INVALID_CURRENCY", http.StatusBadRequest)
+		return
+	}
+	if currency == room.BaseCurrency {
+		http.Error(w, "BASE_CURRENCY_UNCHANGED", http.StatusBadRequest)
+		return
+	}
+	req.RateDate = strings.ToUpper(req.RateDate)
+	if req.RateDate == "" {
+		req.RateDate = RateDateOriginal
+	}
+	if req.RateDate != RateDateOriginal && req.RateDate != RateDateToday {
+		http.Error(w, "INVALID_RATE_DATE", http.StatusBadRequest)
+		return
+	}
+
+	// a room without a base currency has nothing to revalue
+	if room.BaseCurrency == "" {
+		foreignCurrencies := removeCurrency(room.ForeignCurrencies, currency)
+		if err := h.DB.Model(&room).Updates(map[string]interface{}{
+			"base_currency":      currency,
+			"foreign_currencies": foreignCurrencies,
+		}).Error; err != nil {
+			http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+			return
+		}
+		room.BaseCurrency, room.ForeignCurrencies = currency, foreignCurrencies
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"room": room})
+		return
+	}
+
+	var items []models.Item
+	if err := h.DB.Where("room_id = ?", room.ID).Find(&items).Error; err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+
+	revaluation := models.Revaluation{
+		RoomID:               room.ID,
+		FromCurrency:         room.BaseCurrency,
+		ToCurrency:           currency,
+		RateDate:             req.RateDate,
+		OldForeignCurrencies: room.ForeignCurrencies,
+		CreatorID:            userID,
+		Entries:              []models.RevaluationEntry{},
+	}
+	now := time.Now()
+	for i := range items {
+		item := &items[i]
+		entry := models.RevaluationEntry{
+			ItemID:             item.ID,
+			OldAmount:          item.Amount,
+			OldForeignAmount:   item.ForeignAmount,
+			OldForeignCurrency: item.ForeignCurrency,
+			OldFxRate:          item.FxRate,
+		}
+
+		at := item.OccurredAt
+		if req.RateDate == RateDateToday {
+			at = now
+		}
+		if err := h.revalueItem(&room, item, currency, at); err != nil {
+			writeError(w, err, "FX_PROVIDER_ERROR")
+			return
+		}
+
+		entry.NewAmount, entry.NewFxRate = item.Amount, item.FxRate
+		revaluation.Entries = append(revaluation.Entries, entry)
+	}
+
+	var budgets []models.Budget
+	if err := h.DB.Where("room_id = ?", room.ID).Find(&budgets).Error; err != nil {
+		http.Error(w, "DB_ERROR_BUDGETS", http.StatusInternalServerError)
+		return
+	}
+	var alerts []models.BudgetAlert
+	if err := h.DB.Where("room_id = ?", room.ID).Find(&alerts).Error; err != nil {
+		http.Error(w, "DB_ERROR_BUDGETS", http.StatusInternalServerError)
+		return
+	}
+	if len(budgets) > 0 || len(alerts) > 0 {
+		quote, err := h.Rates.Rate(fx.Query{RoomID: room.ID, From: room.BaseCurrency, To: currency, Date: now})
+		if err != nil {
+			writeError(w, rateError(err), "FX_PROVIDER_ERROR")
+			return
+		}
+		revaluation.Budgets, revaluation.BudgetAlerts, err = revalueBudgets(budgets, alerts, room.BaseCurrency, quote.Rate, currency)
+		if err != nil {
+			http.Error(w, "FX_CONVERSION_FAILED", http.StatusBadRequest)
+			return
+		}
+	}
+
+	foreignCurrencies := removeCurrency(room.ForeignCurrencies, currency)
+	if !foreignCurrencies.Contains(room.BaseCurrency) {
+		foreignCurrencies = append(foreignCurrencies, room.BaseCurrency)
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			if err := tx.Model(&models.Item{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+				"amount":           item.Amount,
+				"foreign_amount":   item.ForeignAmount,
+				"foreign_currency": item.ForeignCurrency,
+				"fx_rate":          item.FxRate,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if err := updateBudgetAmounts(tx, revaluation.Budgets, revaluation.BudgetAlerts, false); err != nil {
+			return err
+		}
+		if err := tx.Model(&room).Updates(map[string]interface{}{
+			"base_currency":      currency,
+			"foreign_currencies": foreignCurrencies,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&revaluation).Error
+	}); err != nil {
+		http.Error(w, "DB_ERROR_REVALUATIONS", http.StatusInternalServerError)
+		return
+	}
+	h.invalidateRates(room.ID)
+
+	h.announceRevaluation(&revaluation, items)
+	h.recordActivity(room.ID, userID, ActivityBaseCurrency,
+		map[string]string{"base_currency": revaluation.FromCurrency},
+		map[string]interface{}{"base_currency": revaluation.ToCurrency, "revaluation_id": revaluation.ID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revaluation)
+}
+
+func (h *Handler) GetRevaluations(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomForMember(w, r, ps)
+	if !ok {
+		return
+	}
+
+	revaluations := []models.Revaluation{}
+	if err := h.DB.Where("room_id = ?", room.ID).Order("created_at DESC").Find(&revaluations).Error; err != nil {
+		http.Error(w, "DB_ERROR_REVALUATIONS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revaluations)
+}
+
+// RevertRevaluation restores the base currency, item amounts and budgets from
+// before a revaluation. Only the room's latest revaluation can be reverted,
+// and only while no item or budget has been added or changed since.
+func (h *Handler) RevertRevaluation(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermChangeSettings)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value("userID").(uuid.UUID)
+
+	var revaluation models.Revaluation
+	if err := h.DB.Preload("Entries").Preload("Budgets").Preload("BudgetAlerts").
+		Where("id = ? AND room_id = ?", ps.ByName("revaluationID"), room.ID).
+		First(&revaluation).Error; err != nil {
+		http.Error(w, "REVALUATION_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+	if revaluation.RevertedAt != nil {
+		http.Error(w, "REVALUATION_ALREADY_REVERTED", http.StatusConflict)
+		return
+	}
+
+	var newer int64
+	if err := h.DB.Model(&models.Revaluation{}).
+		Where("room_id = ? AND created_at > ? AND reverted_at IS NULL", room.ID, revaluation.CreatedAt).
+		Count(&newer).Error; err != nil {
+		http.Error(w, "DB_ERROR_REVALUATIONS", http.StatusInternalServerError)
+		return
+	}
+	// reverting a later revaluation touches the items too
+	since := revaluation.CreatedAt
+	var lastRevert *time.Time
+	if err := h.DB.Model(&models.Revaluation{}).Where("room_id = ?", room.ID).
+		Select("MAX(reverted_at)").Scan(&lastRevert).Error; err != nil {
+		http.Error(w, "DB_ERROR_REVALUATIONS", http.StatusInternalServerError)
+		return
+	}
+	if lastRevert != nil && lastRevert.After(since) {
+		since = *lastRevert
+	}
+	var changed int64
+	if err := h.DB.Model(&models.Item{}).
+		Where("room_id = ? AND updated_at > ?", room.ID, since).
+		Count(&changed).Error; err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+	var changedBudgets int64
+	if err := h.DB.Model(&models.Budget{}).
+		Where("room_id = ? AND updated_at > ?", room.ID, since).
+		Count(&changedBudgets).Error; err != nil {
+		http.Error(w, "DB_ERROR_BUDGETS", http.StatusInternalServerError)
+		return
+	}
+	if newer > 0 || changed > 0 || changedBudgets > 0 || room.BaseCurrency != revaluation.ToCurrency {
+		http.Error(w, "REVALUATION_OUTDATED", http.StatusConflict)
+		return
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		for _, entry := range revaluation.Entries {
+			// items deleted since simply stay deleted
+			if err := tx.Model(&models.Item{}).Where("id = ?", entry.ItemID).Updates(map[string]interface{}{
+				"amount":           entry.OldAmount,
+				"foreign_amount":   entry.OldForeignAmount,
+				"foreign_currency": entry.OldForeignCurrency,
+				"fx_rate":          entry.OldFxRate,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if err := updateBudgetAmounts(tx, revaluation.Budgets, revaluation.BudgetAlerts, true); err != nil {
+			return err
+		}
+		if err := tx.Model(&room).Updates(map[string]interface{}{
+			"base_currency":      revaluation.FromCurrency,
+			"foreign_currencies": revaluation.OldForeignCurrencies,
+		}).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		revaluation.RevertedAt = &now
+		revaluation.RevertedBy = &userID
+		return tx.Model(&revaluation).Select("reverted_at", "reverted_by").Updates(&revaluation).Error
+	}); err != nil {
+		http.Error(w, "DB_ERROR_REVALUATIONS", http.StatusInternalServerError)
+		return
+	}
+	h.invalidateRates(room.ID)
+
+	var items []models.Item
+	if err := h.DB.Where("room_id = ?", room.ID).Find(&items).Error; err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+	h.announceRevaluation(&revaluation, items)
+	h.recordActivity(room.ID, userID, ActivityRevaluationRevert,
+		map[string]interface{}{"base_currency": revaluation.ToCurrency, "revaluation_id": revaluation.ID},
+		map[string]string{"base_currency": revaluation.FromCurrency})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revaluation)
+}
+
+// revalueItem converts item into currency from the currency it was originally
+// entered in, keeping that original amount as the item's foreign amount.
+func (h *Handler) revalueItem(room *models.Room, item *models.Item, currency string, at time.Time) error {
+	original := models.Money{Amount: item.Amount, Currency: room.BaseCurrency}
+	if item.ForeignCurrency != "" {
+		original = models.Money{Amount: item.ForeignAmount, Currency: item.ForeignCurrency}
+	}
+
+	if original.Currency == currency {
+		item.Amount = original.Amount
+		item.ForeignAmount, item.ForeignCurrency, item.FxRate = 0, "", ""
+		return nil
+	}
+
+	quote, err := h.Rates.Rate(fx.Query{RoomID: room.ID, From: original.Currency, To: currency, Date: at})
+	if err != nil {
+		return rateError(err)
+	}
+	converted, err := original.Convert(quote.Rate, currency)
+	if err != nil {
+		return &requestError{code: "FX_CONVERSION_FAILED", status: http.StatusBadRequest}
+	}
+
+	item.Amount = converted.Amount
+	item.ForeignAmount, item.ForeignCurrency, item.FxRate = original.Amount, original.Currency, quote.Rate
+	return nil
+}
+
+// revalueBudgets converts budgets and their alerts from one currency into
+// another at rate, in place, and returns what is needed to revert them.
+func revalueBudgets(budgets []models.Budget, alerts []models.BudgetAlert, from string, rate models.Rate, currency string) ([]models.RevaluationBudget, []models.RevaluationBudgetAlert, error) {
+	convert := func(amount int64) (int64, error) {
+		converted, err := models.Money{Amount: amount, Currency: from}.Convert(rate, currency)
+		return converted.Amount, err
+	}
+
+	budgetEntries := []models.RevaluationBudget{}
+	for i := range budgets {
+		budget := &budgets[i]
+		amount, err := convert(budget.Amount)
+		if err != nil {
+			return nil, nil, err
+		}
+		budgetEntries = append(budgetEntries, models.RevaluationBudget{BudgetID: budget.ID, OldAmount: budget.Amount, NewAmount: amount})
+		budget.Amount = amount
+	}
+
+	alertEntries := []models.RevaluationBudgetAlert{}
+	for i := range alerts {
+		alert := &alerts[i]
+		spent, err := convert(alert.Spent)
+		if err != nil {
+			return nil, nil, err
+		}
+		budgeted, err := convert(alert.Budgeted)
+		if err != nil {
+			return nil, nil, err
+		}
+		alertEntries = append(alertEntries, models.RevaluationBudgetAlert{
+			BudgetAlertID: alert.ID,
+			OldSpent:      alert.Spent,
+			OldBudgeted:   alert.Budgeted,
+			NewSpent:      spent,
+			NewBudgeted:   budgeted,
+		})
+		alert.Spent, alert.Budgeted = spent, budgeted
+	}
+	return budgetEntries, alertEntries, nil
+}
+
+// updateBudgetAmounts writes the new amounts recorded by a revaluation, or the
+// old ones when reverting it. Budgets deleted since simply stay deleted.
+func updateBudgetAmounts(tx *gorm.DB, budgets []models.RevaluationBudget, alerts []models.RevaluationBudgetAlert, revert bool) error {
+	for _, entry := range budgets {
+		amount := entry.NewAmount
+		if revert {
+			amount = entry.OldAmount
+		}
+		if err := tx.Model(&models.Budget{}).Where("id = ?", entry.BudgetID).Update("amount", amount).Error; err != nil {
+			return err
+		}
+	}
+	for _, entry := range alerts {
+		spent, budgeted := entry.NewSpent, entry.NewBudgeted
+		if revert {
+			spent, budgeted = entry.OldSpent, entry.OldBudgeted
+		}
+		if err := tx.Model(&models.BudgetAlert{}).Where("id = ?", entry.BudgetAlertID).Updates(map[string]interface{}{
+			"spent":    spent,
+			"budgeted": budgeted,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Handler) announceRevaluation(revaluation *models.Revaluation, items []models.Item) {
+	simplifiedItems, _ := h.resimplify(revaluation.RoomID)
+
+	announced := *revaluation
+	announced.Entries = nil
+	h.pushUpdatesToAllClients(revaluation.RoomID.String(), &SSEUpdateInfo{
+		Event:           RevaluationEvent,
+		UpdatedItems:    items,
+		SimplifiedItems: simplifiedItems,
+		Revaluation:     &announced,
+	})
+}
+
+func removeCurrency(currencies models.CurrencyList, currency string) models.CurrencyList {
+	res := models.CurrencyList{}
+	for _, c := range currencies {
+		if c != currency {
+			res = append(res, c)
+		}
+	}
+	return res
+}
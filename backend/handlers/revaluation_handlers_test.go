@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"backend/models"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestRevalueBudgets(t *testing.T) {
+	budgets := []models.Budget{{ID: uuid.New(), Amount: 10000}}
+	alerts := []models.BudgetAlert{{ID: uuid.New(), BudgetID: budgets[0].ID, Threshold: 80, Spent: 8050, Budgeted: 10000}}
+
+	// 1 EUR buys 160.5 JPY, which has no minor unit
+	budgetEntries, alertEntries, err := revalueBudgets(budgets, alerts, "EUR", "160.5", "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(16050), budgets[0].Amount)
+	assert.Equal(t, int64(12920), alerts[0].Spent)
+	assert.Equal(t, int64(16050), alerts[0].Budgeted)
+	// the alert still sits at its threshold
+	assert.GreaterOrEqual(t, alerts[0].Spent*100, alerts[0].Budgeted*int64(alerts[0].Threshold))
+
+	assert.Equal(t, []models.RevaluationBudget{{BudgetID: budgets[0].ID, OldAmount: 10000, NewAmount: 16050}}, budgetEntries)
+	assert.Equal(t, []models.RevaluationBudgetAlert{{
+		BudgetAlertID: alerts[0].ID,
+		OldSpent:      8050,
+		OldBudgeted:   10000,
+		NewSpent:      12920,
+		NewBudgeted:   16050,
+	}}, alertEntries)
+}
+
+func TestRevalueBudgets_Overflow(t *testing.T) {
+	budgets := []models.Budget{{ID: uuid.New(), Amount: 1 << 62}}
+	_, _, err := revalueBudgets(budgets, nil, "EUR", "1000", "USD")
+	assert.Error(t, err)
+	assert.Equal(t, int64(1<<62), budgets[0].Amount)
+}
+
+func TestUpdateBudgetAmounts_Revert(t *testing.T) {
+	// updates otherwise open a transaction, which needs a connection
+	db := dryRunDB(t).Session(&gorm.Session{SkipDefaultTransaction: true})
+	var statements [][]interface{}
+	db.Callback().Update().After("gorm:update").Register("test:record", func(tx *gorm.DB) {
+		statements = append(statements, tx.Statement.Vars)
+	})
+
+	budgetID, alertID := uuid.New(), uuid.New()
+	budgets := []models.RevaluationBudget{{BudgetID: budgetID, OldAmount: 10000, NewAmount: 16050}}
+	alerts := []models.RevaluationBudgetAlert{{BudgetAlertID: alertID, OldSpent: 8050, OldBudgeted: 10000, NewSpent: 12920, NewBudgeted: 16050}}
+
+	assert.NoError(t, updateBudgetAmounts(db, budgets, alerts, true))
+	assert.Len(t, statements, 2)
+	assert.Equal(t, int64(10000), statements[0][0])
+	assert.Equal(t, budgetID, statements[0][len(statements[0])-1])
+	assert.Equal(t, []interface{}{int64(10000), int64(8050), alertID}, statements[1])
+}
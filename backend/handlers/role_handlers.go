@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const (
+	RoleAdmin  string = "ADMIN"
+	RoleMember string = "MEMBER"
+	RoleViewer string = "VIEWER"
+)
+
+const RoleChangedEvent = "role_changed"
+
+type Permission int
+
+const (
+	PermViewRoom Permission = iota
+	PermAddItems
+	PermEditOwnItems
+	PermEditAnyItems
+	PermManageMembers
+	PermChangeSettings
+	PermDeleteRoom
+	PermRecordSettlements
+	PermArchiveRoom
+	PermCloneRoom
+)
+
+// rolePermissions is the permission matrix. Settings cover currencies, rates,
+// budgets and the FX mode.
+var rolePermissions = map[string][]Permission{
+	RoleAdmin:  {PermViewRoom, PermAddItems, PermEditOwnItems, PermEditAnyItems, PermManageMembers, PermChangeSettings, PermDeleteRoom, PermRecordSettlements, PermArchiveRoom, PermCloneRoom},
+	RoleMember: {PermViewRoom, PermAddItems, PermEditOwnItems, PermRecordSettlements},
+	RoleViewer: {PermViewRoom},
+}
+
+// archivedPermissions are the only permissions still granted in an archived
+// room, which otherwise is read-only.
+var archivedPermissions = map[Permission]bool{
+	PermViewRoom:          true,
+	PermRecordSettlements: true,
+	PermArchiveRoom:       true,
+	PermDeleteRoom:        true,
+	PermCloneRoom:         true,
+}
+
+// templatePermissions are refused in template rooms, which hold no items.
+var templatePermissions = map[Permission]bool{
+	PermAddItems:          true,
+	PermRecordSettlements: true,
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func roleCan(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// canEditItem tells whether roomUser may change or delete item. Items created
+// before creators were recorded belong to whoever paid.
+func canEditItem(roomUser models.RoomUser, item models.Item) bool {
+	if roleCan(roomUser.Role, PermEditAnyItems) {
+		return true
+	}
+	owner := item.CreatorID
+	if owner == uuid.Nil {
+		owner = item.ToUserID
+	}
+	return owner == roomUser.UserID && roleCan(roomUser.Role, PermEditOwnItems)
+}
+
+// UpdateMemberRole promotes or demotes a member. A room always keeps at least
+// one admin. Placeholders never act, so their role cannot be changed.
+func (h *Handler) UpdateMemberRole(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermManageMembers); !ok {
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+	role := strings.ToUpper(req.Role)
+	if _, ok := rolePermissions[role]; !ok {
+		http.Error(w, "INVALID_ROLE", http.StatusBadRequest)
+		return
+	}
+
+	var target models.RoomUser
+	if err := h.DB.Where("room_id = ? AND user_id = ? AND status = ?", roomID, ps.ByName("userID"), MemberIn).First(&target).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "USER_NOT_IN_ROOM", http.StatusNotFound)
+		} else {
+			http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		}
+		return
+	}
+	var placeholder int64
+	if err := h.DB.Model(&models.User{}).Where("id = ? AND placeholder_room_id IS NOT NULL", target.UserID).Count(&placeholder).Error; err != nil {
+		http.Error(w, "DB_ERROR_USERS", http.StatusInternalServerError)
+		return
+	}
+	if placeholder > 0 {
+		http.Error(w, "PLACEHOLDER_ROLE", http.StatusConflict)
+		return
+	}
+
+	if target.Role == RoleAdmin && role != RoleAdmin {
+		lastAdmin, err := h.isLastAdmin(roomID, target.UserID)
+		if err != nil {
+			http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+			return
+		}
+		if lastAdmin {
+			http.Error(w, "LAST_ADMIN", http.StatusConflict)
+			return
+		}
+	}
+
+	if err := h.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id = ?", roomID, target.UserID).
+		Update("role", role).Error; err != nil {
+		http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		return
+	}
+	before := target
+	target.Role = role
+	h.recordActivity(roomID, userID, ActivityRoleChanged, before, target)
+
+	h.pushUpdatesToAllClients(roomID.String(), &SSEUpdateInfo{
+		Event:  RoleChangedEvent,
+		Member: &target,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(target)
+}
+
+// isLastAdmin tells whether userID is the only admin still in the room.
+func (h *Handler) isLastAdmin(roomID uuid.UUID, userID uuid.UUID) (bool, error) {
+	var otherAdmins int64
+	err := otherMembers(h.DB, roomID, userID).
+		Where("room_users.role = ?", RoleAdmin).
+		Count(&otherAdmins).Error
+	return otherAdmins == 0, err
+}
+
+// otherMembers selects the room's current members other than userID, leaving
+// out placeholders, which cannot act for the room.
+func otherMembers(db *gorm.DB, roomID uuid.UUID, userID uuid.UUID) *gorm.DB {
+	return db.Model(&models.RoomUser{}).
+		Joins("JOIN users ON users.id = room_users.user_id").
+		Where("room_users.room_id = ? AND room_users.user_id != ? AND room_users.status = ?", roomID, userID, MemberIn).
+		Where("users.placeholder_room_id IS NULL")
+}
+
+// requireRoomPermission writes an error response and returns false unless
+// userID is currently a member of roomID whose role grants perm, and the room
+// is not archived unless perm is one of archivedPermissions. Template rooms
+// refuse templatePermissions.
+func (h *Handler) requireRoomPermission(w http.ResponseWriter, roomID uuid.UUID, userID uuid.UUID, perm Permission) (models.RoomUser, bool) {
+	var roomUser models.RoomUser
+	if err := h.DB.Where("user_id = ? AND room_id = ? AND status = ?", userID, roomID, MemberIn).First(&roomUser).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "USER_NOT_IN_ROOM", http.StatusForbidden)
+		} else {
+			http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		}
+		return roomUser, false
+	}
+	if !roleCan(roomUser.Role, perm) {
+		http.Error(w, "PERMISSION_DENIED", http.StatusForbidden)
+		return roomUser, false
+	}
+	if !archivedPermissions[perm] && !h.requireRoomOpen(w, roomID) {
+		return roomUser, false
+	}
+	if templatePermissions[perm] && !h.requireNotTemplate(w, roomID) {
+		return roomUser, false
+	}
+	return roomUser, true
+}
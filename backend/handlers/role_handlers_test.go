@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"backend/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// memberDB answers membership lookups with a member of the given role. Count
+// queries find one row when their SQL contains any of the matching fragments,
+// e.g. "archived_at IS NOT NULL" for an archived room.
+func memberDB(t *testing.T, role string, matching ...string) *gorm.DB {
+	db := dryRunDB(t)
+	db.Callback().Query().Replace("gorm:query", func(tx *gorm.DB) {
+		callbacks.BuildQuerySQL(tx)
+		switch dest := tx.Statement.Dest.(type) {
+		case *models.RoomUser:
+			*dest = models.RoomUser{Role: role, Status: MemberIn}
+			tx.RowsAffected = 1
+		case *int64:
+			tx.RowsAffected = 1
+			for _, fragment := range matching {
+				if strings.Contains(tx.Statement.SQL.String(), fragment) {
+					*dest = 1
+				}
+			}
+		}
+	})
+	return db
+}
+
+func TestRoleCan(t *testing.T) {
+	for _, tc := range []struct {
+		perm                  Permission
+		admin, member, viewer bool
+	}{
+		{PermViewRoom, true, true, true},
+		{PermAddItems, true, true, false},
+		{PermEditOwnItems, true, true, false},
+		{PermEditAnyItems, true, false, false},
+		{PermManageMembers, true, false, false},
+		{PermChangeSettings, true, false, false},
+		{PermDeleteRoom, true, false, false},
+		{PermRecordSettlements, true, true, false},
+		{PermArchiveRoom, true, false, false},
+		{PermCloneRoom, true, false, false},
+	} {
+		assert.Equal(t, tc.admin, roleCan(RoleAdmin, tc.perm), "admin %d", tc.perm)
+		assert.Equal(t, tc.member, roleCan(RoleMember, tc.perm), "member %d", tc.perm)
+		assert.Equal(t, tc.viewer, roleCan(RoleViewer, tc.perm), "viewer %d", tc.perm)
+		assert.False(t, roleCan("", tc.perm), "no role %d", tc.perm)
+	}
+}
+
+func TestCanEditItem(t *testing.T) {
+	me, other := uuid.New(), uuid.New()
+	for _, tc := range []struct {
+		name     string
+		role     string
+		item     models.Item
+		editable bool
+	}{
+		{"own item", RoleMember, models.Item{CreatorID: me, ToUserID: other}, true},
+		{"someone else's item", RoleMember, models.Item{CreatorID: other, ToUserID: me}, false},
+		{"admin edits any item", RoleAdmin, models.Item{CreatorID: other, ToUserID: other}, true},
+		{"viewer cannot edit own item", RoleViewer, models.Item{CreatorID: me, ToUserID: me}, false},
+		// items from before creators were recorded belong to whoever paid
+		{"legacy item paid by me", RoleMember, models.Item{ToUserID: me}, true},
+		{"legacy item paid by someone else", RoleMember, models.Item{FromUserID: me, ToUserID: other}, false},
+	} {
+		roomUser := models.RoomUser{UserID: me, Role: tc.role}
+		assert.Equal(t, tc.editable, canEditItem(roomUser, tc.item), tc.name)
+	}
+}
+
+func TestRequireRoomPermission_AdminRoutes(t *testing.T) {
+	adminPerms := []Permission{PermEditAnyItems, PermManageMembers, PermChangeSettings, PermDeleteRoom}
+	for _, role := range []string{RoleViewer, RoleMember, RoleAdmin} {
+		h := &Handler{DB: memberDB(t, role)}
+		for _, perm := range adminPerms {
+			w := httptest.NewRecorder()
+			_, ok := h.requireRoomPermission(w, uuid.New(), uuid.New(), perm)
+			if role == RoleAdmin {
+				assert.True(t, ok, "%s %d", role, perm)
+				continue
+			}
+			assert.False(t, ok, "%s %d", role, perm)
+			assert.Equal(t, http.StatusForbidden, w.Code, "%s %d", role, perm)
+			assert.Equal(t, "PERMISSION_DENIED\n", w.Body.String())
+		}
+	}
+}
+
+func TestRequireRoomPermission_Archived(t *testing.T) {
+	h := &Handler{DB: memberDB(t, RoleAdmin, "archived_at IS NOT NULL")}
+	for _, tc := range []struct {
+		perm    Permission
+		allowed bool
+	}{
+		{PermViewRoom, true},
+		{PermAddItems, false},
+		{PermEditOwnItems, false},
+		{PermEditAnyItems, false},
+		{PermManageMembers, false},
+		{PermChangeSettings, false},
+		{PermDeleteRoom, true},
+		{PermRecordSettlements, true},
+		{PermArchiveRoom, true},
+		{PermCloneRoom, true},
+	} {
+		w := httptest.NewRecorder()
+		_, ok := h.requireRoomPermission(w, uuid.New(), uuid.New(), tc.perm)
+		assert.Equal(t, tc.allowed, ok, "perm %d", tc.perm)
+		if !tc.allowed {
+			assert.Equal(t, http.StatusConflict, w.Code, "perm %d", tc.perm)
+			assert.Equal(t, "ROOM_ARCHIVED\n", w.Body.String())
+		}
+	}
+}
+
+func TestRequireRoomPermission_Template(t *testing.T) {
+	h := &Handler{DB: memberDB(t, RoleAdmin, "is_template")}
+	for _, tc := range []struct {
+		perm    Permission
+		allowed bool
+	}{
+		{PermViewRoom, true},
+		{PermAddItems, false},
+		{PermEditOwnItems, true},
+		{PermEditAnyItems, true},
+		{PermManageMembers, true},
+		{PermChangeSettings, true},
+		{PermDeleteRoom, true},
+		{PermRecordSettlements, false},
+		{PermArchiveRoom, true},
+		{PermCloneRoom, true},
+	} {
+		w := httptest.NewRecorder()
+		_, ok := h.requireRoomPermission(w, uuid.New(), uuid.New(), tc.perm)
+		assert.Equal(t, tc.allowed, ok, "perm %d", tc.perm)
+		if !tc.allowed {
+			assert.Equal(t, http.StatusConflict, w.Code, "perm %d", tc.perm)
+			assert.Equal(t, "ROOM_IS_TEMPLATE\n", w.Body.String())
+		}
+	}
+}
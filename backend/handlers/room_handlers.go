@@ -4,14 +4,56 @@ import (
 	"backend/models"
 	"encoding/json"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
 	"gorm.io/gorm"
 )
 
+const RoomSummaryRecentItems = 20
+
+const (
+	RoomDeletedEvent = "room_deleted"
+	MemberLeftEvent  = "member_left"
+)
+
+// Resolutions for a member leaving with an outstanding balance.
+const (
+	LeaveSettle   = "SETTLE"
+	LeaveHandOver = "HAND_OVER"
+)
+
+const (
+	MemberIn      string = "IN"
+	MemberLeft    string = "LEFT"
+	MemberPending string = "PENDING"
+	// MemberRejected marks a join request an admin turned down.
+	MemberRejected string = "REJECTED"
+)
+
 type CreateRoomRequest struct {
-	RoomName string `json:"roomName"`
+	RoomName            string `json:"roomName"`
+	RequireItemApproval bool   `json:"requireItemApproval"`
+	RequireJoinApproval bool   `json:"requireJoinApproval"`
+	BaseCurrency        string `json:"baseCurrency"`
+}
+
+type LeaveRoomRequest struct {
+	Resolution string     `json:"resolution"`
+	TransferTo *uuid.UUID `json:"transfer_to"`
+}
+
+type RoomSummary struct {
+	ItemCount int64               `json:"item_count"`
+	Totals    map[string]int64    `json:"totals"`
+	Balances  map[uuid.UUID]int64 `json:"balances"`
+	// DisplayBalances holds Balances in the requested display currency.
+	DisplayBalances map[uuid.UUID]int64 `json:"display_balances,omitempty"`
+	FirstItemAt     *time.Time          `json:"first_item_at"`
+	LastItemAt      *time.Time          `json:"last_item_at"`
+	RecentItems     []models.Item       `json:"recent_items"`
 }
 
 func (h *Handler) GetRoomInfo(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -23,7 +65,7 @@ func (h *Handler) GetRoomInfo(w http.ResponseWriter, r *http.Request, ps httprou
 
 	userID := r.Context().Value("userID").(uuid.UUID)
 	var roomUser models.RoomUser
-	if err := h.DB.Where("user_id = ? AND room_id = ?", userID, roomID).First(&roomUser).Error; err != nil {
+	if err := h.DB.Where("user_id = ? AND room_id = ? AND status = ?", userID, roomID, MemberIn).First(&roomUser).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "User does not belong to this room", http.StatusNotFound)
 		} else {
@@ -35,43 +77,170 @@ func (h *Handler) GetRoomInfo(w http.ResponseWriter, r *http.Request, ps httprou
 	var room models.Room
 	if err := h.DB.First(&room, roomID).Error; err != nil {
 		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	conversion, err := h.displayConversion(&room, r)
+	if err != nil {
+		writeError(w, err, "FX_PROVIDER_ERROR")
+		return
 	}
 
 	var users []models.User
 	if err := h.DB.Table("room_users").
-		Select("users.id, users.name").
+		Select("users.id, users.name, users.placeholder_room_id").
 		Joins("JOIN users ON users.id = room_users.user_id").
-		Where("room_users.room_id = ?", roomID).
+		Where("room_users.room_id = ? AND room_users.status IN ?", roomID, []string{MemberIn, MemberLeft}).
 		Find(&users).Error; err != nil {
 		http.Error(w, "Failed to retrieve users", http.StatusInternalServerError)
 		return
 	}
 
-	var items []models.Item
-	if err := h.DB.Where("room_id = ?", roomID).Order("created_at ASC").Find(&items).Error; err != nil {
-		http.Error(w, "Failed to retrieve items", http.StatusInternalServerError)
+	simplifiedItems := h.loadSimplifiedItems(roomID)
+
+	roles := map[uuid.UUID]string{}
+	var roomUsers []models.RoomUser
+	if err := h.DB.Where("room_id = ? AND status = ?", roomID, MemberIn).Find(&roomUsers).Error; err != nil {
+		http.Error(w, "Failed to retrieve users", http.StatusInternalServerError)
 		return
 	}
-
-	var simplifiedItems []models.SimplifiedItem
-
-	if cachedSimplifiedItems, cacheFound := h.RoomToSimplifiedItems.Load(roomID); cacheFound {
-		simplifiedItems = cachedSimplifiedItems.([]models.SimplifiedItem)
-	} else {
-		computedSimplifiedItems, _ := h.simplifyAndStore(roomID, DefaultAlgo)
-		simplifiedItems = computedSimplifiedItems
+	for _, ru := range roomUsers {
+		roles[ru.UserID] = ru.Role
 	}
 
 	response := map[string]interface{}{
 		"room":            room,
-		"items":           items,
 		"users":           users,
+		"roles":           roles,
+		"role":            roomUser.Role,
 		"simplifiedItems": simplifiedItems,
 	}
+	if conversion != nil {
+		displayed, err := displaySimplifiedItems(simplifiedItems, conversion)
+		if err != nil {
+			writeError(w, err, "FX_CONVERSION_FAILED")
+			return
+		}
+		response["simplifiedItems"] = displayed
+		response["conversion"] = conversion
+	}
+
+	// the full history is opt-in; by default it is left to the paginated
+	// GetItems
+	if r.URL.Query().Get("view") != "full" {
+		summary, err := h.getRoomSummary(roomID)
+		if err != nil {
+			http.Error(w, "Failed to retrieve items", http.StatusInternalServerError)
+			return
+		}
+		if conversion != nil {
+			if summary.DisplayBalances, err = displayBalances(summary.Balances, conversion); err != nil {
+				writeError(w, err, "FX_CONVERSION_FAILED")
+				return
+			}
+		}
+		response["summary"] = summary
+	} else {
+		var items []models.Item
+		if err := h.DB.Where("room_id = ?", roomID).Order("occurred_at ASC").Find(&items).Error; err != nil {
+			http.Error(w, "Failed to retrieve items", http.StatusInternalServerError)
+			return
+		}
+		response["items"] = items
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+func (h *Handler) getRoomSummary(roomID uuid.UUID) (*RoomSummary, error) {
+	summary := RoomSummary{
+		Totals:      map[string]int64{},
+		Balances:    map[uuid.UUID]int64{},
+		RecentItems: []models.Item{},
+	}
+
+	var typeTotals []struct {
+		TransactionType string
+		Count           int64
+		Total           int64
+		FirstAt         time.Time
+		LastAt          time.Time
+	}
+	if err := h.DB.Model(&models.Item{}).
+		Select("transaction_type, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total, MIN(occurred_at) AS first_at, MAX(occurred_at) AS last_at").
+		Where("room_id = ? AND status = ?", roomID, ItemApproved).
+		Group("transaction_type").
+		Scan(&typeTotals).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range typeTotals {
+		summary.ItemCount += t.Count
+		summary.Totals[t.TransactionType] = t.Total
+		if summary.FirstItemAt == nil || t.FirstAt.Before(*summary.FirstItemAt) {
+			firstAt := t.FirstAt
+			summary.FirstItemAt = &firstAt
+		}
+		if summary.LastItemAt == nil || t.LastAt.After(*summary.LastItemAt) {
+			lastAt := t.LastAt
+			summary.LastItemAt = &lastAt
+		}
+	}
+
+	balances, err := h.roomBalances(roomID)
+	if err != nil {
+		return nil, err
+	}
+	summary.Balances = balances
+
+	if err := h.DB.Where("room_id = ?", roomID).
+		Order("occurred_at DESC, id DESC").
+		Limit(RoomSummaryRecentItems).
+		Find(&summary.RecentItems).Error; err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+// roomBalances returns each user's net position in the room: positive when
+// they are owed money, negative when they owe.
+func (h *Handler) roomBalances(roomID uuid.UUID) (map[uuid.UUID]int64, error) {
+	var room models.Room
+	if err := h.DB.First(&room, "id = ?", roomID).Error; err != nil {
+		return nil, err
+	}
+	if room.FxMode == FxModeFloating {
+		var items []models.Item
+		if err := h.DB.Where("room_id = ? AND status = ?", roomID, ItemApproved).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		floating, _ := h.floatItems(&room, items)
+		return balancesOf(floating)
+	}
+
+	var rows []struct {
+		UserID  uuid.UUID
+		Balance int64
+	}
+	if err := h.DB.Raw(`
+		SELECT user_id, SUM(amount) AS balance FROM (
+			SELECT to_user_id AS user_id, amount FROM items WHERE room_id = ? AND status = ? AND from_user_id != to_user_id
+			UNION ALL
+			SELECT from_user_id AS user_id, -amount FROM items WHERE room_id = ? AND status = ? AND from_user_id != to_user_id
+		) AS entries
+		GROUP BY user_id`, roomID, ItemApproved, roomID, ItemApproved).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	balances := map[uuid.UUID]int64{}
+	for _, row := range rows {
+		balances[row.UserID] = row.Balance
+	}
+	return balances, nil
+}
+
 func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	var createRoomRequest CreateRoomRequest
 	if err := json.NewDecoder(r.Body).Decode(&createRoomRequest); err != nil {
@@ -84,9 +253,28 @@ func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request, _ httproute
 		return
 	}
 
+	baseCurrency := strings.ToUpper(strings.TrimSpace(createRoomRequest.BaseCurrency))
+	if baseCurrency != "" {
+		if err := models.ValidateCurrency(baseCurrency); err != nil {
+			http.Error(w, "INVALID_CURRENCY", http.StatusBadRequest)
+			return
+		}
+	}
+
 	userID := r.Context().Value("userID").(uuid.UUID)
 	user := models.User{ID: userID}
-	room := models.Room{Name: createRoomRequest.RoomName}
+	joinCode, err := models.NewUniqueJoinCode(h.DB)
+	if err != nil {
+		http.Error(w, "JOIN_CODE_ERROR", http.StatusInternalServerError)
+		return
+	}
+	room := models.Room{
+		Name:                createRoomRequest.RoomName,
+		BaseCurrency:        baseCurrency,
+		RequireItemApproval: createRoomRequest.RequireItemApproval,
+		RequireJoinApproval: createRoomRequest.RequireJoinApproval,
+		JoinCode:            &joinCode,
+	}
 
 	if err := h.DB.Create(&room).Error; err != nil {
 		http.Error(w, "ERROR_DB_ROOMS", http.StatusInternalServerError)
@@ -96,7 +284,8 @@ func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request, _ httproute
 	var roomUser = models.RoomUser{
 		RoomID: room.ID,
 		UserID: user.ID,
-		Status: "IN",
+		Status: MemberIn,
+		Role:   RoleAdmin,
 	}
 	if err := h.DB.Where("room_id = ? AND user_id = ?", roomUser.RoomID, roomUser.UserID).FirstOrCreate(&roomUser).Error; err != nil {
 		http.Error(w, "ERROR_DB_ROOMUSERS", http.StatusInternalServerError)
@@ -107,6 +296,9 @@ func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request, _ httproute
 	json.NewEncoder(w).Encode(room)
 }
 
+// JoinRoom adds the caller to the room through an invite, given as ?invite=
+// or in the body. Rooms requiring approval hold the caller as PENDING until
+// an admin decides.
 func (h *Handler) JoinRoom(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	roomID := ps.ByName("roomID")
 	userIDFromJWT := r.Context().Value("userID").(uuid.UUID)
@@ -123,34 +315,96 @@ func (h *Handler) JoinRoom(w http.ResponseWriter, r *http.Request, ps httprouter
 		return
 	}
 
-	var roomUser = models.RoomUser{
-		RoomID: room.ID,
-		UserID: userIDFromJWT,
-		Status: "IN",
+	var existing models.RoomUser
+	err := h.DB.Where("room_id = ? AND user_id = ?", room.ID, userIDFromJWT).First(&existing).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		return
 	}
+	found := err == nil
 
-	result := h.DB.Model(&models.RoomUser{}).
-		Where("room_id = ? AND user_id = ?", roomUser.RoomID, roomUser.UserID).
-		Update("status", "IN")
+	var req JoinRoomRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	if token := r.URL.Query().Get("invite"); token != "" {
+		req.Invite = token
+	}
 
-	if result.RowsAffected == 0 {
-		if err := h.DB.Create(&roomUser).Error; err != nil {
-			http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
-			return
-		}
-	} else if result.Error != nil {
+	// placeholder invites are claimed even by users who are already members
+	invite, inviteErr := h.checkInvite(req.Invite)
+	claiming := inviteErr == nil && invite.PlaceholderID != nil
+	if !claiming && found && (existing.Status == MemberIn || existing.Status == MemberPending) {
+		h.writeJoinResponse(w, room, existing.Status)
+		return
+	}
+	if room.ArchivedAt != nil {
+		http.Error(w, "ROOM_ARCHIVED", http.StatusConflict)
+		return
+	}
+	if claiming {
+		h.claimPlaceholder(w, room, user, req.Invite, *invite.PlaceholderID)
+		return
+	}
+
+	if err := h.redeemInvite(h.DB, room.ID, req.Invite); err != nil {
+		writeError(w, err, "DB_ERROR_INVITES")
+		return
+	}
+
+	h.admitMember(w, room, user, existing, found)
+}
+
+// admitMember makes user a member of room, or a pending one if the room
+// requires approval. existing is their former membership, if found.
+func (h *Handler) admitMember(w http.ResponseWriter, room models.Room, user models.User, existing models.RoomUser, found bool) {
+	roomID := room.ID.String()
+
+	status := MemberIn
+	if room.RequireJoinApproval {
+		status = MemberPending
+	}
+
+	// rejoining keeps the role the user had before leaving
+	var err error
+	roomUser := existing
+	if found {
+		err = h.DB.Model(&models.RoomUser{}).
+			Where("room_id = ? AND user_id = ?", room.ID, user.ID).
+			Update("status", status).Error
+	} else {
+		roomUser = models.RoomUser{RoomID: room.ID, UserID: user.ID, Status: status, Role: RoleMember}
+		err = h.DB.Create(&roomUser).Error
+	}
+	if err != nil {
 		http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
 		return
 	}
+	roomUser.Status = status
 
-	h.pushUpdatesToOtherClients(roomID, userIDFromJWT.String(), &SSEUpdateInfo{
-		NewUser: &user,
-	})
+	action := ActivityMemberJoined
+	if status == MemberPending {
+		action = ActivityMemberRequested
+	}
+	h.recordActivity(room.ID, user.ID, action, nil, roomUser)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(room)
+	if status == MemberPending {
+		h.pushUpdatesToOtherClients(roomID, user.ID.String(), &SSEUpdateInfo{
+			Event:  MemberPendingEvent,
+			Member: &roomUser,
+		})
+	} else {
+		h.pushUpdatesToOtherClients(roomID, user.ID.String(), &SSEUpdateInfo{
+			NewUser: &user,
+		})
+	}
+
+	h.writeJoinResponse(w, room, status)
 }
 
+// LeaveRoom marks the caller as having left. A member whose balance is not
+// settled has to choose a resolution: SETTLE records transfers paying off
+// their part of the settlement plan, HAND_OVER moves the balance to TransferTo.
+// Items still awaiting approval or disputed have to be dealt with first. In
+// floating rooms the leaver's foreign items keep the rates they left at.
 func (h *Handler) LeaveRoom(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	userID := r.Context().Value("userID").(uuid.UUID)
 	roomID := ps.ByName("roomID")
@@ -166,9 +420,8 @@ func (h *Handler) LeaveRoom(w http.ResponseWriter, r *http.Request, ps httproute
 		return
 	}
 
-	if err := h.DB.Model(&models.RoomUser{}).
-		Where("room_id = ? AND user_id = ?", roomID, userID).
-		Update("status", "LEFT").Error; err != nil {
+	var roomUser models.RoomUser
+	if err := h.DB.Where("room_id = ? AND user_id = ? AND status = ?", room.ID, userID, MemberIn).First(&roomUser).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "User does not belong to this room", http.StatusNotFound)
 		} else {
@@ -177,6 +430,271 @@ func (h *Handler) LeaveRoom(w http.ResponseWriter, r *http.Request, ps httproute
 		return
 	}
 
+	// the last admin has to hand over before leaving anyone behind
+	if roomUser.Role == RoleAdmin {
+		var others int64
+		if err := otherMembers(h.DB, room.ID, userID).Count(&others).Error; err != nil {
+			http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+			return
+		}
+		lastAdmin, err := h.isLastAdmin(room.ID, userID)
+		if err != nil {
+			http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+			return
+		}
+		if lastAdmin && others > 0 {
+			http.Error(w, "LAST_ADMIN", http.StatusConflict)
+			return
+		}
+	}
+
+	var unresolved int64
+	if err := h.DB.Model(&models.Item{}).
+		Where("room_id = ? AND status IN ?", room.ID, []string{ItemPending, ItemDisputed}).
+		Where("from_user_id = ? OR to_user_id = ? OR creator_id = ?", userID, userID, userID).
+		Count(&unresolved).Error; err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+	if unresolved > 0 {
+		http.Error(w, "PENDING_ITEMS", http.StatusConflict)
+		return
+	}
+
+	var req LeaveRoomRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	balances, err := h.roomBalances(room.ID)
+	if err != nil {
+		http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+		return
+	}
+	balance := balances[userID]
+
+	newItems := []models.Item{}
+	if balance != 0 {
+		switch strings.ToUpper(req.Resolution) {
+		case LeaveSettle:
+			newItems = settlementItems(room.ID, userID, h.loadSimplifiedItems(room.ID))
+		case LeaveHandOver:
+			if req.TransferTo == nil || *req.TransferTo == userID {
+				http.Error(w, "INVALID_TRANSFER_TARGET", http.StatusBadRequest)
+				return
+			}
+			if err := h.DB.Where("room_id = ? AND user_id = ? AND status = ?", room.ID, *req.TransferTo, MemberIn).First(&models.RoomUser{}).Error; err != nil {
+				http.Error(w, "INVALID_TRANSFER_TARGET", http.StatusBadRequest)
+				return
+			}
+			newItems = []models.Item{handOverItem(room.ID, userID, *req.TransferTo, balance)}
+		case "":
+			http.Error(w, "OUTSTANDING_BALANCE", http.StatusConflict)
+			return
+		default:
+			http.Error(w, "INVALID_RESOLUTION", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// in rooms requiring approval the counterparties still confirm these
+	if len(newItems) > 0 {
+		if err := h.prepareNewItems(room.ID, userID, newItems); err != nil {
+			writeError(w, err, "DB_ERROR_ITEMS")
+			return
+		}
+	}
+	var locked []models.Item
+	if room.FxMode == FxModeFloating {
+		if locked, err = h.lockedRates(&room, userID); err != nil {
+			http.Error(w, "DB_ERROR_ITEMS", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	before := roomUser
+	roomUser.Status = MemberLeft
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		for _, item := range locked {
+			if err := tx.Model(&models.Item{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+				"amount":       item.Amount,
+				"fx_rate":      item.FxRate,
+				"fx_locked_at": item.FxLockedAt,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if len(newItems) > 0 {
+			if err := tx.Create(&newItems).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.RoomUser{}).
+			Where("room_id = ? AND user_id = ?", room.ID, userID).
+			Update("status", MemberLeft).Error
+	}); err != nil {
+		http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		return
+	}
+
+	if len(newItems) > 0 {
+		h.recordActivity(room.ID, userID, ActivitySettlementRecorded, nil, newItems)
+	}
+	h.recordActivity(room.ID, userID, ActivityMemberLeft, before, roomUser)
+
+	info := &SSEUpdateInfo{Event: MemberLeftEvent, Member: &roomUser}
+	if len(newItems) > 0 {
+		info.NewItems = newItems
+		info.SimplifiedItems, _ = h.resimplify(room.ID)
+	}
+	h.pushUpdatesToOtherClients(roomID, userID.String(), info)
+	if len(newItems) > 0 {
+		h.checkBudgets(room.ID, newItems)
+	}
+
+	response := map[string]interface{}{
+		"message":         "User successfully left the room",
+		"newItems":        newItems,
+		"simplifiedItems": info.SimplifiedItems,
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "User successfully left the room"})
+	json.NewEncoder(w).Encode(response)
+}
+
+// lockedRates returns userID's foreign items with the amounts they float to
+// today, marked so that they no longer float. Fixing them keeps a member who
+// leaves settled whatever rates do afterwards.
+func (h *Handler) lockedRates(room *models.Room, userID uuid.UUID) ([]models.Item, error) {
+	var items []models.Item
+	if err := h.DB.Where("room_id = ? AND status = ?", room.ID, ItemApproved).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	floating, _ := h.floatItems(room, items)
+
+	now := time.Now()
+	res := []models.Item{}
+	for _, item := range floating {
+		if item.ForeignCurrency == "" || item.FxLockedAt != nil || (item.FromUserID != userID && item.ToUserID != userID) {
+			continue
+		}
+		item.FxLockedAt = &now
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+// settlementItems returns the transfers that pay off every part of the
+// settlement plan involving userID.
+func settlementItems(roomID uuid.UUID, userID uuid.UUID, plan []models.SimplifiedItem) []models.Item {
+	items := []models.Item{}
+	groupID := uuid.New()
+	for _, settlement := range plan {
+		if settlement.FromUserID != userID && settlement.ToUserID != userID {
+			continue
+		}
+		// the debtor pays, so the transfer runs against the debt
+		items = append(items, models.Item{
+			RoomID:          roomID,
+			GroupID:         groupID,
+			FromUserID:      settlement.ToUserID,
+			ToUserID:        settlement.FromUserID,
+			Amount:          settlement.Amount,
+			Content:         "Settled on leaving",
+			TransactionType: Transfer,
+		})
+	}
+	return items
+}
+
+// handOverItem returns the transfer moving userID's balance to another member.
+func handOverItem(roomID uuid.UUID, userID uuid.UUID, toUserID uuid.UUID, balance int64) models.Item {
+	item := models.Item{
+		RoomID:          roomID,
+		GroupID:         uuid.New(),
+		FromUserID:      userID,
+		ToUserID:        toUserID,
+		Amount:          balance,
+		Content:         "Balance handed over on leaving",
+		TransactionType: Transfer,
+	}
+	if balance < 0 {
+		item.FromUserID, item.ToUserID, item.Amount = toUserID, userID, -balance
+	}
+	return item
+}
+
+// DeleteRoom removes the room with everything recorded in it.
+func (h *Handler) DeleteRoom(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if _, ok := h.requireRoomPermission(w, roomID, userID, PermDeleteRoom); !ok {
+		return
+	}
+
+	var attachments []models.Attachment
+	if err := h.DB.Where("room_id = ?", roomID).Find(&attachments).Error; err != nil {
+		http.Error(w, "DB_ERROR_ATTACHMENTS", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		revaluationIDs := tx.Model(&models.Revaluation{}).Select("id").Where("room_id = ?", roomID)
+		for _, model := range []interface{}{&models.RevaluationEntry{}, &models.RevaluationBudget{}, &models.RevaluationBudgetAlert{}} {
+			if err := tx.Where("revaluation_id IN (?)", revaluationIDs).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		snapshotIDs := tx.Model(&models.RoomSnapshot{}).Select("id").Where("room_id = ?", roomID)
+		if err := tx.Where("room_snapshot_id IN (?)", snapshotIDs).Delete(&models.SnapshotBalance{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_snapshot_id IN (?)", snapshotIDs).Delete(&models.SnapshotSettlement{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{
+			&models.RoomSnapshot{},
+			&models.Revaluation{},
+			&models.ExchangeRate{},
+			&models.BudgetAlert{},
+			&models.Budget{},
+			&models.Attachment{},
+			&models.Item{},
+			&models.Invite{},
+			&models.Household{},
+			&models.RoomAuditEntry{},
+			&models.RoomUser{},
+		} {
+			if err := tx.Where("room_id = ?", roomID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("placeholder_room_id = ?", roomID).Delete(&models.User{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Room{}, "id = ?", roomID).Error
+	}); err != nil {
+		http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		return
+	}
+
+	for _, attachment := range attachments {
+		h.deleteAttachmentBlobs(attachment)
+	}
+	h.RoomToSimplifiedItems.Delete(roomID)
+	h.RoomToHouseholdItems.Delete(roomID)
+	h.invalidateRates(roomID)
+	h.pushUpdatesToOtherClients(roomID.String(), userID.String(), &SSEUpdateInfo{Event: RoomDeletedEvent})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "ROOM_DELETED"})
+}
+
+// requireRoomMember writes an error response and returns false unless userID
+// is currently a member of roomID.
+func (h *Handler) requireRoomMember(w http.ResponseWriter, roomID uuid.UUID, userID uuid.UUID) bool {
+	_, ok := h.requireRoomPermission(w, roomID, userID, PermViewRoom)
+	return ok
 }
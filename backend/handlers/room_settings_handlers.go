@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+const RoomUpdatedEvent = "room_updated"
+
+// UpdateRoomRequest holds the settings to change; omitted fields are kept.
+// SimplifyAlgo takes the same codes as the algo parameter of SimplifyItems.
+type UpdateRoomRequest struct {
+	Name                *string `json:"name"`
+	BaseCurrency        *string `json:"base_currency"`
+	SimplifyAlgo        *string `json:"simplify_algo"`
+	FxMode              *string `json:"fx_mode"`
+	RequireItemApproval *bool   `json:"require_item_approval"`
+	RequireJoinApproval *bool   `json:"require_join_approval"`
+}
+
+func (h *Handler) UpdateRoom(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomWithPermission(w, r, ps, PermChangeSettings)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value("userID").(uuid.UUID)
+
+	var req UpdateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "INVALID_INPUT", http.StatusBadRequest)
+		return
+	}
+
+	settings := newRoomSettingsChange(&room, userID)
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if len(name) < 5 || len(name) > 20 {
+			http.Error(w, "INVALID_NAME_LENGTH", http.StatusBadRequest)
+			return
+		}
+		settings.set("name", room.Name, name, name)
+	}
+	if req.BaseCurrency != nil {
+		currency := strings.ToUpper(strings.TrimSpace(*req.BaseCurrency))
+		if err := models.ValidateCurrency(currency); err != nil {
+			http.Error(w, "INVALID_CURRENCY", http.StatusBadRequest)
+			return
+		}
+		// amounts are only revalued through ChangeBaseCurrency
+		if room.BaseCurrency != "" && room.BaseCurrency != currency {
+			http.Error(w, "BASE_CURRENCY_ALREADY_SET", http.StatusConflict)
+			return
+		}
+		settings.set("base_currency", room.BaseCurrency, currency, currency)
+		if room.ForeignCurrencies.Contains(currency) {
+			settings.updates["foreign_currencies"] = removeCurrency(room.ForeignCurrencies, currency)
+		}
+	}
+	if req.SimplifyAlgo != nil {
+		algo := strings.TrimSpace(*req.SimplifyAlgo)
+		if algo != "" && !validSimplifyAlgo(algo) {
+			http.Error(w, "INVALID_ALGO", http.StatusBadRequest)
+			return
+		}
+		settings.set("simplify_algo", room.SimplifyAlgo, algo, algo)
+	}
+	if req.FxMode != nil {
+		mode := strings.ToUpper(*req.FxMode)
+		if mode != FxModeLocked && mode != FxModeFloating {
+			http.Error(w, "INVALID_FX_MODE", http.StatusBadRequest)
+			return
+		}
+		settings.set("fx_mode", room.FxMode, mode, mode)
+	}
+	if req.RequireItemApproval != nil {
+		settings.set("require_item_approval", strconv.FormatBool(room.RequireItemApproval), strconv.FormatBool(*req.RequireItemApproval), *req.RequireItemApproval)
+	}
+	if req.RequireJoinApproval != nil {
+		settings.set("require_join_approval", strconv.FormatBool(room.RequireJoinApproval), strconv.FormatBool(*req.RequireJoinApproval), *req.RequireJoinApproval)
+	}
+
+	if err := h.saveRoomSettings(settings); err != nil {
+		http.Error(w, "DB_ERROR_ROOMS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room)
+}
+
+// roomSettingsChange collects changed room settings along with the audit
+// entries recording them.
+type roomSettingsChange struct {
+	room    *models.Room
+	actorID uuid.UUID
+	updates map[string]interface{}
+	entries []models.RoomAuditEntry
+}
+
+func newRoomSettingsChange(room *models.Room, actorID uuid.UUID) *roomSettingsChange {
+	return &roomSettingsChange{room: room, actorID: actorID, updates: map[string]interface{}{}, entries: []models.RoomAuditEntry{}}
+}
+
+// set stores value for field unless its old and new values are the same.
+func (c *roomSettingsChange) set(field string, oldValue string, newValue string, value interface{}) {
+	if oldValue == newValue {
+		return
+	}
+	c.updates[field] = value
+	c.entries = append(c.entries, models.RoomAuditEntry{
+		RoomID:   c.room.ID,
+		ActorID:  c.actorID,
+		Action:   ActivitySettingsChanged,
+		Field:    field,
+		OldValue: oldValue,
+		NewValue: newValue,
+	})
+}
+
+// saveRoomSettings writes the changed settings together with their audit
+// entries, reloads the room and pushes it to everyone in the room. Every
+// endpoint that changes room settings goes through here.
+func (h *Handler) saveRoomSettings(c *roomSettingsChange) error {
+	if len(c.entries) == 0 {
+		return nil
+	}
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(c.room).Updates(c.updates).Error; err != nil {
+			return err
+		}
+		return tx.Create(&c.entries).Error
+	}); err != nil {
+		return err
+	}
+	if err := h.DB.First(c.room, "id = ?", c.room.ID).Error; err != nil {
+		return err
+	}
+
+	info := &SSEUpdateInfo{
+		Event:        RoomUpdatedEvent,
+		Room:         c.room,
+		AuditEntries: c.entries,
+	}
+	_, algoChanged := c.updates["simplify_algo"]
+	_, fxModeChanged := c.updates["fx_mode"]
+	if algoChanged || fxModeChanged {
+		info.SimplifiedItems, _ = h.resimplify(c.room.ID)
+	}
+	h.pushUpdatesToAllClients(c.room.ID.String(), info)
+	return nil
+}
+
+// GetRoomAuditLog lists the room's setting changes, newest first.
+func (h *Handler) GetRoomAuditLog(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	room, ok := h.loadRoomForMember(w, r, ps)
+	if !ok {
+		return
+	}
+
+	entries := []models.RoomAuditEntry{}
+	if err := h.DB.Where("room_id = ? AND field != ''", room.ID).Order("created_at DESC").Limit(200).Find(&entries).Error; err != nil {
+		http.Error(w, "DB_ERROR_AUDIT", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// validSimplifyAlgo accepts the codes GetAlgorithmType knows, since it falls
+// back to no simplification for anything else.
+func validSimplifyAlgo(code string) bool {
+	switch code {
+	case "0", "1", "2":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"backend/models"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomSettingsChange_Set(t *testing.T) {
+	room := models.Room{ID: uuid.New(), BaseCurrency: "EUR", FxMode: FxModeLocked}
+	actorID := uuid.New()
+	settings := newRoomSettingsChange(&room, actorID)
+
+	settings.set("fx_mode", room.FxMode, FxModeLocked, FxModeLocked)
+	assert.Empty(t, settings.updates)
+	assert.Empty(t, settings.entries)
+
+	currencies := models.CurrencyList{"USD", "GBP"}
+	settings.set("foreign_currencies", "", "USD,GBP", currencies)
+	assert.Equal(t, map[string]interface{}{"foreign_currencies": currencies}, settings.updates)
+	assert.Equal(t, []models.RoomAuditEntry{{
+		RoomID:   room.ID,
+		ActorID:  actorID,
+		Action:   ActivitySettingsChanged,
+		Field:    "foreign_currencies",
+		OldValue: "",
+		NewValue: "USD,GBP",
+	}}, settings.entries)
+}
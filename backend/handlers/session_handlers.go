@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"backend/middleware"
+	"backend/models"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"gorm.io/gorm"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// SessionInfo is a session as listed to its user.
+type SessionInfo struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// newSession opens a session for user on the requesting device and returns
+// its access and refresh tokens.
+func (h *Handler) newSession(r *http.Request, user *models.User) (string, string, error) {
+	refreshToken, hash, err := middleware.NewOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hash,
+		UserAgent:        r.UserAgent(),
+		IPAddress:        clientIP(r),
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(middleware.RefreshTokenLifetime),
+	}
+	if err := h.DB.Create(&session).Error; err != nil {
+		return "", "", err
+	}
+
+	token, err := h.Auth.GenerateToken(user, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+// RefreshSession trades a refresh token for a new access token and a new
+// refresh token. Presenting a refresh token that was already traded revokes
+// the session, since it means the token was copied.
+func (h *Handler) RefreshSession(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "INVALID_REQUEST", http.StatusBadRequest)
+		return
+	}
+	hash := middleware.HashOpaqueToken(req.RefreshToken)
+
+	var session models.Session
+	if err := h.DB.First(&session, "refresh_token_hash = ?", hash).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			http.Error(w, "DB_ERROR_SESSIONS", http.StatusInternalServerError)
+			return
+		}
+		h.DB.Model(&models.Session{}).
+			Where("previous_token_hash = ? AND revoked_at IS NULL", hash).
+			Update("revoked_at", time.Now())
+		http.Error(w, "INVALID_REFRESH_TOKEN", http.StatusUnauthorized)
+		return
+	}
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		http.Error(w, "SESSION_REVOKED", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, "id = ?", session.UserID).Error; err != nil {
+		http.Error(w, "USER_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+
+	refreshToken, newHash, err := middleware.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, "TOKEN_GEN_FAIL", http.StatusInternalServerError)
+		return
+	}
+	// the guard keeps two refreshes racing with one token from both winning
+	result := h.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  newHash,
+			"previous_token_hash": hash,
+			"user_agent":          r.UserAgent(),
+			"ip_address":          clientIP(r),
+			"last_seen_at":        now,
+			"expires_at":          now.Add(middleware.RefreshTokenLifetime),
+		})
+	if result.Error != nil {
+		http.Error(w, "DB_ERROR_SESSIONS", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "INVALID_REFRESH_TOKEN", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := h.Auth.GenerateToken(&user, session.ID)
+	if err != nil {
+		http.Error(w, "TOKEN_GEN_FAIL", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"token":        token,
+		"refreshToken": refreshToken,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID := r.Context().Value("userID").(uuid.UUID)
+	sessionID := r.Context().Value("sessionID").(uuid.UUID)
+
+	var sessions []models.Session
+	if err := h.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		http.Error(w, "DB_ERROR_SESSIONS", http.StatusInternalServerError)
+		return
+	}
+
+	res := []SessionInfo{}
+	for _, session := range sessions {
+		res = append(res, SessionInfo{Session: session, Current: session.ID == sessionID})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// RevokeSession logs out one of the caller's sessions, such as a lost device.
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID := r.Context().Value("userID").(uuid.UUID)
+	sessionID, err := uuid.Parse(ps.ByName("sessionID"))
+	if err != nil {
+		http.Error(w, "INVALID_SESSION_ID", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := h.revokeSessions(h.DB.Where("id = ? AND user_id = ?", sessionID, userID))
+	if err != nil {
+		http.Error(w, "DB_ERROR_SESSIONS", http.StatusInternalServerError)
+		return
+	}
+	if revoked == 0 {
+		http.Error(w, "SESSION_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "SESSION_REVOKED"})
+}
+
+// Logout ends the session the request was made with.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	sessionID := r.Context().Value("sessionID").(uuid.UUID)
+	if _, err := h.revokeSessions(h.DB.Where("id = ?", sessionID)); err != nil {
+		http.Error(w, "DB_ERROR_SESSIONS", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "LOGOUT_SUCCESS"})
+}
+
+// LogoutAll ends every session of the caller, this one included.
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	userID := r.Context().Value("userID").(uuid.UUID)
+	revoked, err := h.revokeSessions(h.DB.Where("user_id = ?", userID))
+	if err != nil {
+		http.Error(w, "DB_ERROR_SESSIONS", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"message":  "LOGOUT_SUCCESS",
+		"sessions": revoked,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// revokeSessions revokes the still active sessions matched by scope and
+// returns how many there were.
+func (h *Handler) revokeSessions(scope *gorm.DB) (int64, error) {
+	result := scope.Model(&models.Session{}).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
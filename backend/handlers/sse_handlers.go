@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"backend/models"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -13,6 +14,15 @@ import (
 func (h *Handler) ItemSSEHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	roomID := ps.ByName("roomID")
 
+	roomUUID, err := uuid.Parse(roomID)
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+	if !h.requireRoomMember(w, roomUUID, r.Context().Value("userID").(uuid.UUID)) {
+		return
+	}
+
 	if _, ok := h.RoomClients.Load(roomID); !ok {
 		var chanUserMap sync.Map
 		h.RoomClients.Store(roomID, &chanUserMap)
@@ -45,6 +55,9 @@ func (h *Handler) ItemSSEHandler(w http.ResponseWriter, r *http.Request, ps http
 				http.Error(w, "ERR_JSON_SERIALIZE", http.StatusInternalServerError)
 				return
 			}
+			if info.Event != "" {
+				fmt.Fprintf(w, "event: %s\n", info.Event)
+			}
 			fmt.Fprintf(w, "data: %s\n\n", serializedData)
 			flusher, ok := w.(http.Flusher)
 			if ok {
@@ -56,8 +69,20 @@ func (h *Handler) ItemSSEHandler(w http.ResponseWriter, r *http.Request, ps http
 	}
 }
 
+func (h *Handler) pushUpdatesToAllClients(roomID string, info *SSEUpdateInfo) {
+	h.pushUpdatesToOtherClients(roomID, "", info)
+}
+
 func (h *Handler) pushUpdatesToOtherClients(roomID string, userID string, info *SSEUpdateInfo) {
-	clients, _ := h.RoomClients.Load(roomID)
+	clients, ok := h.RoomClients.Load(roomID)
+	if !ok {
+		return
+	}
+	if id, err := uuid.Parse(roomID); err == nil && info.SimplifiedItems != nil && info.HouseholdSimplifiedItems == nil {
+		if householdItems, ok := h.RoomToHouseholdItems.Load(id); ok {
+			info.HouseholdSimplifiedItems = householdItems.([]models.SimplifiedItem)
+		}
+	}
 	clientMap := clients.(*sync.Map)
 	clientMap.Range(func(ch, value interface{}) bool {
 		clientUID := value.(string)
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/stats"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+// GetRoomStats reports spending statistics for every member of the room,
+// computed from approved items and expressed in the room's base currency.
+func (h *Handler) GetRoomStats(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	if !h.requireRoomMember(w, roomID, userID) {
+		return
+	}
+
+	interval, ok := stats.ParseInterval(r.URL.Query().Get("interval"))
+	if !ok {
+		http.Error(w, "INVALID_INTERVAL", http.StatusBadRequest)
+		return
+	}
+
+	var room models.Room
+	if err := h.DB.First(&room, "id = ?", roomID).Error; err != nil {
+		http.Error(w, "ROOM_NOT_FOUND", http.StatusNotFound)
+		return
+	}
+
+	var memberIDs []uuid.UUID
+	if err := h.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND status = ?", roomID, MemberIn).
+		Pluck("user_id", &memberIDs).Error; err != nil {
+		http.Error(w, "DB_ERROR_ROOMUSERS", http.StatusInternalServerError)
+		return
+	}
+
+	var items []models.Item
+	if err := h.DB.Where("room_id = ? AND status = ?", roomID, ItemApproved).Order("occurred_at ASC").Find(&items).Error; err != nil {
+		http.Error(w, "DB_ERROR_ROOM_ITEMS", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"currency": room.BaseCurrency,
+		"members":  stats.Compute(items, memberIDs, interval),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
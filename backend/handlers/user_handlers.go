@@ -18,14 +18,50 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request, ps httprou
 	json.NewEncoder(w).Encode(response)
 }
 
+// UserCredentials is what registering and logging in take. The password is
+// sent in plain text as password_hash, as it always has been.
+type UserCredentials struct {
+	Name     string  `json:"name"`
+	Password string  `json:"password_hash"`
+	Email    *string `json:"email"`
+}
+
+// RoomMember is a user as seen from a room they belong to or asked to join.
+type RoomMember struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Role   string    `json:"role"`
+	Status string    `json:"status"`
+	// Placeholder marks a member without an account.
+	Placeholder bool       `json:"placeholder"`
+	HouseholdID *uuid.UUID `json:"household_id"`
+}
+
+// GetUsersInRoom lists the room's members; admins also see pending join
+// requests.
 func (h *Handler) GetUsersInRoom(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	roomID := ps.ByName("roomID")
-	var users []models.User
+	roomID, err := uuid.Parse(ps.ByName("roomID"))
+	if err != nil {
+		http.Error(w, "INVALID_ROOM_ID", http.StatusBadRequest)
+		return
+	}
+
+	roomUser, ok := h.requireRoomPermission(w, roomID, r.Context().Value("userID").(uuid.UUID), PermViewRoom)
+	if !ok {
+		return
+	}
+
+	statuses := []string{MemberIn}
+	if roleCan(roomUser.Role, PermManageMembers) {
+		statuses = append(statuses, MemberPending)
+	}
+
+	users := []RoomMember{}
 	if err := h.DB.Table("room_users").
-		Select("users.id, users.name").
+		Select("users.id, users.name, room_users.role, room_users.status, users.placeholder_room_id IS NOT NULL AS placeholder, room_users.household_id").
 		Joins("JOIN users ON users.id = room_users.user_id").
-		Where("room_users.room_id = ? AND room_users.status != ?", roomID, "LEFT").
-		Find(&users).Error; err != nil {
+		Where("room_users.room_id = ? AND room_users.status IN ?", roomID, statuses).
+		Scan(&users).Error; err != nil {
 		http.Error(w, "Failed to retrieve users", http.StatusInternalServerError)
 		return
 	}
@@ -45,17 +81,33 @@ func (h *Handler) GetUserInfo(w http.ResponseWriter, r *http.Request, ps httprou
 
 	var rooms []models.Room
 	if err := h.DB.Table("room_users").
-		Select("rooms.id, rooms.name, rooms.created_at, rooms.updated_at").
+		Select("rooms.id, rooms.name, rooms.is_template, rooms.archived_at, rooms.archived_by, rooms.created_at, rooms.updated_at").
 		Joins("JOIN rooms ON rooms.id = room_users.room_id").
-		Where("room_users.user_id = ? AND room_users.status != ?", userId, "LEFT").
+		Where("room_users.user_id = ? AND room_users.status = ?", userId, MemberIn).
 		Find(&rooms).Error; err != nil {
 		http.Error(w, "Failed to retrieve rooms belonging to user", http.StatusInternalServerError)
 		return
 	}
 
+	// archived rooms and templates are listed apart so they stay out of the
+	// active list
+	active, archived, templates := []models.Room{}, []models.Room{}, []models.Room{}
+	for _, room := range rooms {
+		switch {
+		case room.ArchivedAt != nil:
+			archived = append(archived, room)
+		case room.IsTemplate:
+			templates = append(templates, room)
+		default:
+			active = append(active, room)
+		}
+	}
+
 	response := map[string]interface{}{
-		"user":  user,
-		"rooms": rooms,
+		"user":          user,
+		"rooms":         active,
+		"archivedRooms": archived,
+		"templates":     templates,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -94,14 +146,14 @@ func (h *Handler) AddUserToRoom(w http.ResponseWriter, r *http.Request, ps httpr
 }
 
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	var user models.User
-
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	var req UserCredentials
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "INVALID_REQUEST", http.StatusBadRequest)
 		return
 	}
+	user := models.User{Name: req.Name, PasswordHash: req.Password, Email: req.Email}
 
-	if len(user.PasswordHash) < 6 {
+	if len(user.PasswordHash) < MinPasswordLength {
 		http.Error(w, "INVALID_PW_LENGTH", http.StatusBadRequest)
 		return
 	}
@@ -111,11 +163,27 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request, ps httprout
 	}
 
 	var existingUser models.User
-	if err := h.DB.Where("name = ?", user.Name).First(&existingUser).Error; err == nil {
+	if err := h.DB.Where("name = ? AND placeholder_room_id IS NULL", user.Name).First(&existingUser).Error; err == nil {
 		http.Error(w, "USERNAME_ALREADY_EXIST", http.StatusBadRequest)
 		return
 	}
 
+	if user.Email != nil {
+		email, err := normalizeEmail(*user.Email)
+		if err != nil {
+			http.Error(w, "INVALID_EMAIL", http.StatusBadRequest)
+			return
+		}
+		user.Email = email
+		if taken, err := h.emailTaken(email, uuid.Nil); err != nil {
+			http.Error(w, "ERROR_DB_USERS", http.StatusInternalServerError)
+			return
+		} else if taken {
+			http.Error(w, "EMAIL_ALREADY_EXIST", http.StatusBadRequest)
+			return
+		}
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.PasswordHash), bcrypt.DefaultCost)
 	if err != nil {
 		http.Error(w, "PW_HASH_FAIL", http.StatusInternalServerError)
@@ -137,33 +205,34 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request, ps httprout
 }
 
 func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	var req UserCredentials
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "INVALID_REQUEST", http.StatusBadRequest)
 		return
 	}
 
 	var foundUser models.User
-	if err := h.DB.First(&foundUser, "name = ?", user.Name).Error; err != nil {
+	if err := h.DB.First(&foundUser, "name = ? AND placeholder_room_id IS NULL", req.Name).Error; err != nil {
 		http.Error(w, "USERNAME_NOT_FOUND", http.StatusNotFound)
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(foundUser.PasswordHash), []byte(user.PasswordHash)); err != nil {
+	if err := bcrypt.CompareHashAndPassword([]byte(foundUser.PasswordHash), []byte(req.Password)); err != nil {
 		http.Error(w, "INCORRECT_PW", http.StatusUnauthorized)
 		return
 	}
 
-	tokenString, err := h.Auth.GenerateToken(&foundUser)
+	tokenString, refreshToken, err := h.newSession(r, &foundUser)
 	if err != nil {
 		http.Error(w, "TOKEN_GEN_FAIL", http.StatusInternalServerError)
 		return
 	}
 
 	response := map[string]interface{}{
-		"message": "LOGIN_SUCCESS",
-		"user":    foundUser,
-		"token":   tokenString,
+		"message":      "LOGIN_SUCCESS",
+		"user":         foundUser,
+		"token":        tokenString,
+		"refreshToken": refreshToken,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const localSender = "buytogether@localhost"
+
+// FileMailer writes each message to a .eml file below Dir instead of sending
+// it, for development and tests.
+type FileMailer struct {
+	Dir string
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir}, nil
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	data, err := msg.Bytes(localSender)
+	if err != nil {
+		return err
+	}
+	name := time.Now().UTC().Format("20060102T150405") + "-" + uuid.NewString() + ".eml"
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o640)
+}
+
+// LogMailer writes messages to the server log instead of sending them.
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	if _, err := msg.Bytes(localSender); err != nil {
+		return err
+	}
+	log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
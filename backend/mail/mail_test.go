@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_Bytes(t *testing.T) {
+	data, err := Message{To: "a@example.com", Subject: "Hello", Body: "line one\nline two"}.Bytes("b@example.com")
+	assert.NoError(t, err)
+
+	s := string(data)
+	assert.Contains(t, s, "From: b@example.com\r\n")
+	assert.Contains(t, s, "To: a@example.com\r\n")
+	assert.Contains(t, s, "Subject: Hello\r\n")
+	assert.True(t, strings.HasSuffix(s, "\r\n\r\nline one\r\nline two"))
+}
+
+func TestMessage_Bytes_RejectsHeaderInjection(t *testing.T) {
+	_, err := Message{To: "a@example.com\r\nBcc: c@example.com", Subject: "Hello"}.Bytes("b@example.com")
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+
+	_, err = Message{To: "a@example.com", Subject: "Hello\nBcc: c@example.com"}.Bytes("b@example.com")
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestFileMailer_Send(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, m.Send(Message{To: "a@example.com", Subject: "Reset", Body: "token"}))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.True(t, strings.HasSuffix(entries[0].Name(), ".eml"))
+
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "Subject: Reset\r\n")
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"backend/algorithm"
+	"backend/fx"
+	"backend/mail"
 	"backend/middleware"
+	"backend/migrations"
+	"backend/storage"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
@@ -33,6 +38,12 @@ func main() {
 	dbHost := os.Getenv("DB_HOST")
 	dbSSLMode := os.Getenv("DB_SSLMODE")
 	jwtkey := os.Getenv("JWT_SECRET")
+	fxRatesFile := os.Getenv("FX_RATES_FILE")
+	passwordResetURL := os.Getenv("PASSWORD_RESET_URL")
+	attachmentDir := os.Getenv("ATTACHMENT_DIR")
+	if attachmentDir == "" {
+		attachmentDir = "attachments"
+	}
 
 	dsn := fmt.Sprintf("user=%s password=%s dbname=%s port=%s host=%s sslmode=%s",
 		dbUser, dbPassword, dbName, dbPort, dbHost, dbSSLMode)
@@ -42,10 +53,41 @@ func main() {
 		log.Fatal(err)
 	}
 
-	db.AutoMigrate(&models.Room{}, &models.Item{}, &models.User{}, &models.RoomUser{})
+	db.AutoMigrate(&models.Room{}, &models.Item{}, &models.User{}, &models.Session{}, &models.PasswordReset{}, &models.RoomUser{}, &models.Household{}, &models.RoomAuditEntry{}, &models.Invite{}, &models.Attachment{}, &models.Budget{}, &models.BudgetAlert{}, &models.ExchangeRate{}, &models.Revaluation{}, &models.RevaluationEntry{}, &models.RevaluationBudget{}, &models.RevaluationBudgetAlert{}, &models.RoomSnapshot{}, &models.SnapshotBalance{}, &models.SnapshotSettlement{})
+
+	if err := migrations.Run(db); err != nil {
+		log.Fatal(err)
+	}
 
 	simplifier := algorithm.Simplifier{}
-	auth := middleware.Auth{JWTKey: []byte(jwtkey)}
+	auth := middleware.Auth{JWTKey: []byte(jwtkey), DB: db}
+
+	blobs, err := storage.NewLocalStore(attachmentDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// room overrides win over the reference rates file
+	rateProviders := fx.ChainProvider{fx.NewManualProvider(db)}
+	if fxRatesFile != "" {
+		fileProvider, err := fx.NewFileProvider(fxRatesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rateProviders = append(rateProviders, fileProvider)
+	}
+	rates := fx.NewCachingProvider(rateProviders, 10*time.Minute)
+
+	// without an SMTP server, mail is written to MAIL_DIR or else logged
+	var mailer mail.Mailer = mail.LogMailer{}
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		mailer = mail.NewSMTPMailer(smtpHost, os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM"))
+	} else if mailDir := os.Getenv("MAIL_DIR"); mailDir != "" {
+		mailer, err = mail.NewFileMailer(mailDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// roomID -> clientUID -> chan *SSEUpdateInfo
 	var roomClients sync.Map
@@ -53,12 +95,22 @@ func main() {
 	// roomID -> []models.SimplifiedItem
 	var roomToSimplifiedItems sync.Map
 
+	// roomID -> []models.SimplifiedItem with households settling as one
+	var roomToHouseholdItems sync.Map
+
 	h := handlers.Handler{
 		DB:                    db,
 		Simplifier:            &simplifier,
 		Auth:                  &auth,
 		RoomClients:           &roomClients,
 		RoomToSimplifiedItems: &roomToSimplifiedItems,
+		RoomToHouseholdItems:  &roomToHouseholdItems,
+		Blobs:                 blobs,
+		Rates:                 rates,
+		Mailer:                mailer,
+		PasswordResetURL:      passwordResetURL,
+		ResetIPLimiter:        middleware.NewRateLimiter(handlers.PasswordResetsPerIP, handlers.PasswordResetWindow),
+		ResetEmailLimiter:     middleware.NewRateLimiter(handlers.PasswordResetsPerEmail, handlers.PasswordResetWindow),
 	}
 
 	router := httprouter.New()
@@ -70,30 +122,95 @@ func main() {
 	router.POST("/rooms", auth.JWTAuth(h.CreateRoom))
 	router.GET("/rooms/:roomID", auth.JWTAuth(h.GetRoomInfo))
 	router.POST("/rooms/:roomID", auth.JWTAuth(h.JoinRoom))
+	router.PATCH("/rooms/:roomID", auth.JWTAuth(h.UpdateRoom))
+	router.DELETE("/rooms/:roomID", auth.JWTAuth(h.DeleteRoom))
+	router.GET("/rooms/:roomID/audit", auth.JWTAuth(h.GetRoomAuditLog))
+	router.GET("/rooms/:roomID/activity", auth.JWTAuth(h.GetActivity))
+	router.POST("/rooms/:roomID/archive", auth.JWTAuth(h.ArchiveRoom))
+	router.POST("/rooms/:roomID/reopen", auth.JWTAuth(h.ReopenRoom))
+	router.GET("/rooms/:roomID/snapshots", auth.JWTAuth(h.GetRoomSnapshots))
+	router.POST("/rooms/:roomID/clone", auth.JWTAuth(h.CloneRoom))
 	router.POST("/rooms/:roomID/leave", auth.JWTAuth(h.LeaveRoom))
-	router.GET("/rooms/:roomID/users", h.GetUsersInRoom)
-	// TODO: Roles (Admin, User, ...), InviteUser, ApproveUser
+	router.GET("/rooms/:roomID/users", auth.JWTAuth(h.GetUsersInRoom))
+	router.POST("/rooms/:roomID/placeholders", auth.JWTAuth(h.CreatePlaceholder))
+	router.GET("/rooms/:roomID/households", auth.JWTAuth(h.GetHouseholds))
+	router.POST("/rooms/:roomID/households", auth.JWTAuth(h.CreateHousehold))
+	router.PUT("/rooms/:roomID/households/:householdID", auth.JWTAuth(h.UpdateHousehold))
+	router.DELETE("/rooms/:roomID/households/:householdID", auth.JWTAuth(h.DeleteHousehold))
+	router.PUT("/rooms/:roomID/users/:userID/role", auth.JWTAuth(h.UpdateMemberRole))
+	router.POST("/rooms/:roomID/users/:userID/approve", auth.JWTAuth(h.ApproveMember))
+	router.POST("/rooms/:roomID/users/:userID/reject", auth.JWTAuth(h.RejectMember))
+	router.GET("/rooms/:roomID/invites", auth.JWTAuth(h.GetInvites))
+	router.POST("/rooms/:roomID/invites", auth.JWTAuth(h.CreateInvite))
+	router.DELETE("/rooms/:roomID/invites/:inviteID", auth.JWTAuth(h.RevokeInvite))
+	router.GET("/invites/:token", auth.JWTAuth(h.GetInvite))
+	router.GET("/rooms/:roomID/join_code", auth.JWTAuth(h.GetJoinCode))
+	router.POST("/rooms/:roomID/join_code", auth.JWTAuth(h.RegenerateJoinCode))
+	router.DELETE("/rooms/:roomID/join_code", auth.JWTAuth(h.DeleteJoinCode))
+	router.POST("/join/:code", auth.JWTAuth(h.JoinRoomByCode))
 
 	// Items
 	router.GET("/rooms/:roomID/items", auth.JWTAuth(h.GetItems))
 	router.DELETE("/rooms/:roomID/items/:itemID", auth.JWTAuth(h.DeleteItem))
 	router.GET("/rooms/:roomID/simplified_items", auth.JWTAuth(h.GetSimplifiedItems))
 	router.POST("/rooms/:roomID/simplify", auth.JWTAuth(h.SimplifyItems))
-	// TODO: support FX
 	router.POST("/rooms/:roomID/items", auth.JWTAuth(h.CreateTransfer))
 	router.POST("/rooms/:roomID/items/groupExpense", auth.JWTAuth(h.CreateGroupExpense))
 	router.POST("/rooms/:roomID/items/groupIncome", auth.JWTAuth(h.CreateGroupIncome))
 	router.DELETE("/rooms/:roomID/groups/:groupID", auth.JWTAuth(h.DeleteGroupedItems))
 	router.GET("/rooms/:roomID/sse", auth.JWTAuth(h.ItemSSEHandler))
 
+	// FX
+	router.GET("/rooms/:roomID/currencies", auth.JWTAuth(h.GetCurrencies))
+	router.PUT("/rooms/:roomID/currencies", auth.JWTAuth(h.UpdateCurrencies))
+	router.PUT("/rooms/:roomID/currencies/base", auth.JWTAuth(h.ChangeBaseCurrency))
+	router.GET("/rooms/:roomID/revaluations", auth.JWTAuth(h.GetRevaluations))
+	router.POST("/rooms/:roomID/revaluations/:revaluationID/revert", auth.JWTAuth(h.RevertRevaluation))
+	router.GET("/rooms/:roomID/fx_report", auth.JWTAuth(h.GetFxReport))
+	router.GET("/rooms/:roomID/rates", auth.JWTAuth(h.GetExchangeRates))
+	router.GET("/rooms/:roomID/rates/quote", auth.JWTAuth(h.GetExchangeRateQuote))
+	router.POST("/rooms/:roomID/rates", auth.JWTAuth(h.CreateExchangeRate))
+	router.DELETE("/rooms/:roomID/rates/:rateID", auth.JWTAuth(h.DeleteExchangeRate))
+
+	router.GET("/rooms/:roomID/approvals", auth.JWTAuth(h.GetApprovals))
+	router.POST("/rooms/:roomID/approvals/:itemID/approve", auth.JWTAuth(h.ApproveItem))
+	router.POST("/rooms/:roomID/approvals/:itemID/dispute", auth.JWTAuth(h.DisputeItem))
+	router.POST("/rooms/:roomID/approvals/:itemID/resolve", auth.JWTAuth(h.ResolveItem))
+	router.GET("/rooms/:roomID/stats", auth.JWTAuth(h.GetRoomStats))
+	router.GET("/rooms/:roomID/budgets", auth.JWTAuth(h.GetBudgets))
+	router.POST("/rooms/:roomID/budgets", auth.JWTAuth(h.CreateBudget))
+	router.DELETE("/rooms/:roomID/budgets/:budgetID", auth.JWTAuth(h.DeleteBudget))
+	router.GET("/rooms/:roomID/budget_alerts", auth.JWTAuth(h.GetBudgetAlerts))
+	router.GET("/rooms/:roomID/export", auth.JWTAuth(h.ExportLedger))
+	router.POST("/rooms/:roomID/import", auth.JWTAuth(h.ImportLedger))
+
+	// Attachments
+	router.POST("/rooms/:roomID/groups/:groupID/attachments", auth.JWTAuth(h.UploadAttachment))
+	router.GET("/rooms/:roomID/groups/:groupID/attachments", auth.JWTAuth(h.GetAttachments))
+	router.GET("/rooms/:roomID/attachments/:attachmentID", auth.JWTAuth(h.DownloadAttachment))
+	router.GET("/rooms/:roomID/attachments/:attachmentID/thumbnail", auth.JWTAuth(h.GetAttachmentThumbnail))
+	router.DELETE("/rooms/:roomID/attachments/:attachmentID", auth.JWTAuth(h.DeleteAttachment))
+
 	// Users
 	router.POST("/users/register", h.CreateUser)
 	router.POST("/users/login", h.LoginUser)
+	router.POST("/users/password", auth.JWTAuth(h.ChangePassword))
+	router.POST("/users/password_reset", h.RequestPasswordReset)
+	router.POST("/users/password_reset/confirm", h.ConfirmPasswordReset)
+	router.PUT("/users/email", auth.JWTAuth(h.UpdateEmail))
+	router.GET("/account", auth.JWTAuth(h.GetAccount))
+	router.POST("/users/logout", auth.JWTAuth(h.Logout))
+	router.POST("/users/logout_all", auth.JWTAuth(h.LogoutAll))
 	router.GET("/users/:userID", auth.JWTAuth(h.GetUserInfo))
 
+	// Sessions
+	router.POST("/sessions/refresh", h.RefreshSession)
+	router.GET("/sessions", auth.JWTAuth(h.GetSessions))
+	router.DELETE("/sessions/:sessionID", auth.JWTAuth(h.RevokeSession))
+
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"http://158.69.215.13:3000", "http://localhost:3000"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"Content-Type", "Authorization"},
 	})
 
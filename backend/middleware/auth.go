@@ -3,6 +3,10 @@ package middleware
 import (
 	"backend/models"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
@@ -10,10 +14,19 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+const (
+	AccessTokenLifetime  = 15 * time.Minute
+	RefreshTokenLifetime = 30 * 24 * time.Hour
+	// LastSeenInterval limits how often a session's last activity is written.
+	LastSeenInterval = time.Minute
 )
 
 type Auth struct {
 	JWTKey []byte
+	DB     *gorm.DB
 }
 
 func (a *Auth) JWTAuth(next httprouter.Handle) httprouter.Handle {
@@ -45,15 +58,57 @@ func (a *Auth) JWTAuth(next httprouter.Handle) httprouter.Handle {
 		userID, err := uuid.Parse(claims.Subject)
 		if err != nil {
 			http.Error(w, "INVALID_USER_ID", http.StatusInternalServerError)
+			return
+		}
+
+		// tokens from before sessions existed carry no session and are refused
+		sessionID, err := uuid.Parse(claims.Id)
+		if err != nil {
+			http.Error(w, "INVALID_TOKEN", http.StatusUnauthorized)
+			return
+		}
+		active, err := a.sessionActive(sessionID, userID)
+		if err != nil {
+			http.Error(w, "DB_ERROR_SESSIONS", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			http.Error(w, "SESSION_REVOKED", http.StatusUnauthorized)
+			return
 		}
+
 		ctx := context.WithValue(r.Context(), "userID", userID)
+		ctx = context.WithValue(ctx, "sessionID", sessionID)
 		next(w, r.WithContext(ctx), ps)
 	}
 }
 
-func (a *Auth) GenerateToken(user *models.User) (string, error) {
-	expirationTime := time.Now().Add(12 * time.Hour)
+// sessionActive tells whether the session exists and is neither revoked nor
+// expired, and notes that it was just used. Failing to look the session up is
+// an error rather than a refusal.
+func (a *Auth) sessionActive(sessionID uuid.UUID, userID uuid.UUID) (bool, error) {
+	var session models.Session
+	if err := a.DB.First(&session, "id = ? AND user_id = ?", sessionID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return false, nil
+	}
+	if now.Sub(session.LastSeenAt) > LastSeenInterval {
+		a.DB.Model(&session).Update("last_seen_at", now)
+	}
+	return true, nil
+}
+
+// GenerateToken issues a short-lived access token for the user's session.
+func (a *Auth) GenerateToken(user *models.User, sessionID uuid.UUID) (string, error) {
+	expirationTime := time.Now().Add(AccessTokenLifetime)
 	claims := &jwt.StandardClaims{
+		Id:        sessionID.String(),
 		Subject:   user.ID.String(),
 		ExpiresAt: expirationTime.Unix(),
 	}
@@ -64,3 +119,19 @@ func (a *Auth) GenerateToken(user *models.User) (string, error) {
 	}
 	return tokenString, nil
 }
+
+// NewOpaqueToken returns a random token, such as a refresh token, along with
+// the hash under which it is stored.
+func NewOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashOpaqueToken(token), nil
+}
+
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
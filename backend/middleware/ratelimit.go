@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter allows each key, such as a client address, at most Limit
+// events within any Window. It keeps its counts in memory, so they reset when
+// the server restarts.
+type RateLimiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu        sync.Mutex
+	hits      map[string][]time.Time
+	lastSweep time.Time
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{Limit: limit, Window: window, hits: map[string][]time.Time{}}
+}
+
+// Allow records an event for key and tells whether it is within the limit.
+// Refused events are not counted.
+func (l *RateLimiter) Allow(key string) bool {
+	return l.allowAt(key, time.Now())
+}
+
+func (l *RateLimiter) allowAt(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	since := now.Add(-l.Window)
+	// keys that went quiet are dropped once per window
+	if now.Sub(l.lastSweep) > l.Window {
+		for k, hits := range l.hits {
+			if len(recent(hits, since)) == 0 {
+				delete(l.hits, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	hits := recent(l.hits[key], since)
+	if len(hits) >= l.Limit {
+		l.hits[key] = hits
+		return false
+	}
+	l.hits[key] = append(hits, now)
+	return true
+}
+
+// recent returns the hits after since, which are kept in order.
+func recent(hits []time.Time, since time.Time) []time.Time {
+	for i, hit := range hits {
+		if hit.After(since) {
+			return hits[i:]
+		}
+	}
+	return nil
+}
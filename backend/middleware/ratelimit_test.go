@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(2, time.Hour)
+	start := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, l.allowAt("a", start))
+	assert.True(t, l.allowAt("a", start.Add(time.Minute)))
+	assert.False(t, l.allowAt("a", start.Add(2*time.Minute)))
+	// other keys are counted apart
+	assert.True(t, l.allowAt("b", start.Add(2*time.Minute)))
+
+	// the window slides: the first event no longer counts an hour later
+	assert.True(t, l.allowAt("a", start.Add(time.Hour+time.Second)))
+	assert.False(t, l.allowAt("a", start.Add(time.Hour+2*time.Second)))
+
+	// quiet keys are forgotten
+	l.allowAt("c", start.Add(3*time.Hour))
+	assert.NotContains(t, l.hits, "a")
+	assert.NotContains(t, l.hits, "b")
+}
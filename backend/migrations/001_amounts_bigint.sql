@@ -0,0 +1,4 @@
+-- Amounts are stored in the minor units of their currency (see models.Money).
+-- Widen them to 64 bits so large sums in low-value currencies cannot overflow.
+ALTER TABLE items ALTER COLUMN amount TYPE BIGINT;
+ALTER TABLE items ALTER COLUMN foreign_amount TYPE BIGINT;
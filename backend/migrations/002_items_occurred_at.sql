@@ -0,0 +1,9 @@
+-- Items can be backdated: occurred_at is when the expense happened, created_at
+-- only records when it was entered.
+ALTER TABLE items ADD COLUMN IF NOT EXISTS occurred_at TIMESTAMP;
+UPDATE items SET occurred_at = created_at WHERE occurred_at IS NULL;
+ALTER TABLE items ALTER COLUMN occurred_at SET NOT NULL;
+ALTER TABLE items ALTER COLUMN occurred_at SET DEFAULT CURRENT_TIMESTAMP;
+
+DROP INDEX IF EXISTS idx_items_room_id_created_at;
+CREATE INDEX IF NOT EXISTS idx_items_room_id_occurred_at ON items(room_id, occurred_at, id);
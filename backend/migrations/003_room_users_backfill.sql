@@ -0,0 +1,8 @@
+-- Memberships from before statuses existed are current ones.
+UPDATE room_users SET status = 'IN' WHERE status IS NULL OR status = '';
+
+-- Rooms from before roles existed give every current member the admin rights
+-- they had; pending and departed members get none.
+UPDATE room_users SET role = 'ADMIN'
+WHERE status = 'IN'
+  AND room_id NOT IN (SELECT room_id FROM room_users WHERE role = 'ADMIN');
@@ -0,0 +1,5 @@
+-- Account names are unique through idx_users_name, which leaves placeholder
+-- members out, so the old table-wide constraints go.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_name_key;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_name ON users(name) WHERE placeholder_room_id IS NULL;
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func init() {
+	goMigrations = append(goMigrations, Migration{Version: "005_rooms_join_codes", Func: backfillJoinCodes})
+}
+
+// backfillJoinCodes gives every room created before join codes one, as new
+// rooms get one when created.
+func backfillJoinCodes(tx *gorm.DB) error {
+	var roomIDs []uuid.UUID
+	if err := tx.Model(&models.Room{}).Where("join_code IS NULL").Pluck("id", &roomIDs).Error; err != nil {
+		return err
+	}
+	for _, roomID := range roomIDs {
+		code, err := models.NewUniqueJoinCode(tx)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Room{}).Where("id = ?", roomID).Update("join_code", code).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Migration is one step of the schema's history, applied once per database.
+// Most are numbered .sql files in this directory; the ones that need Go set
+// Func and add themselves to goMigrations.
+type Migration struct {
+	Version string
+	SQL     string
+	Func    func(tx *gorm.DB) error
+}
+
+// goMigrations are the migrations written in Go, which sort among the .sql
+// files by version.
+var goMigrations = []Migration{}
+
+// schemaMigration records an applied migration.
+type schemaMigration struct {
+	Version   string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// All returns every migration in the order they are applied.
+func All() ([]Migration, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	all := append([]Migration{}, goMigrations...)
+	for _, name := range names {
+		data, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, Migration{Version: strings.TrimSuffix(name, ".sql"), SQL: string(data)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+
+	for i := 1; i < len(all); i++ {
+		if all[i].Version == all[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration %s", all[i].Version)
+		}
+	}
+	return all, nil
+}
+
+// Run applies the migrations db has not had yet, each in its own
+// transaction. It runs after AutoMigrate, so migrations can rely on the
+// current tables and columns existing.
+func Run(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+	all, err := All()
+	if err != nil {
+		return err
+	}
+
+	var applied []string
+	if err := db.Model(&schemaMigration{}).Pluck("version", &applied).Error; err != nil {
+		return err
+	}
+	done := map[string]bool{}
+	for _, version := range applied {
+		done[version] = true
+	}
+
+	for _, m := range all {
+		if done[m.Version] {
+			continue
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if m.Func != nil {
+				if err := m.Func(tx); err != nil {
+					return err
+				}
+			} else if err := tx.Exec(m.SQL).Error; err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: m.Version, AppliedAt: time.Now()}).Error
+		}); err != nil {
+			return fmt.Errorf("migration %s: %w", m.Version, err)
+		}
+		log.Printf("applied migration %s", m.Version)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAll_Ordered(t *testing.T) {
+	all, err := All()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, all)
+
+	numbered := regexp.MustCompile(`^\d{3}_[a-z0-9_]+$`)
+	for i, m := range all {
+		assert.Regexp(t, numbered, m.Version)
+		assert.True(t, m.SQL != "" || m.Func != nil, m.Version)
+		if i > 0 {
+			assert.Less(t, all[i-1].Version, m.Version)
+		}
+	}
+	assert.Equal(t, "001_amounts_bigint", all[0].Version)
+
+	// Go migrations sort among the .sql files
+	assert.Equal(t, "005_rooms_join_codes", all[4].Version)
+	assert.NotNil(t, all[4].Func)
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// Rate is an exchange rate kept as a decimal string to avoid float rounding:
+// the number of units of the quote currency bought by one unit of the base.
+// The empty Rate is stored as NULL.
+type Rate string
+
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() <= 0 || strings.ContainsAny(s, "/eE") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidRate, s)
+	}
+	return normalizeRate(s), nil
+}
+
+func (r Rate) Rat() (*big.Rat, error) {
+	rat, ok := new(big.Rat).SetString(string(r))
+	if !ok || rat.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRate, string(r))
+	}
+	return rat, nil
+}
+
+// Inverse returns 1/r with up to 12 decimal places.
+func (r Rate) Inverse() (Rate, error) {
+	rat, err := r.Rat()
+	if err != nil {
+		return "", err
+	}
+	return RateFromRat(new(big.Rat).Inv(rat)), nil
+}
+
+func RateFromRat(rat *big.Rat) Rate {
+	return normalizeRate(rat.FloatString(12))
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	if r == "" {
+		return nil, nil
+	}
+	return string(r), nil
+}
+
+func (r *Rate) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*r = ""
+	case []byte:
+		*r = normalizeRate(string(v))
+	case string:
+		*r = normalizeRate(v)
+	case float64:
+		*r = RateFromRat(new(big.Rat).SetFloat64(v))
+	default:
+		return fmt.Errorf("cannot scan %T into Rate", value)
+	}
+	return nil
+}
+
+// normalizeRate strips insignificant trailing zeros, e.g. "1.250000" -> "1.25".
+func normalizeRate(s string) Rate {
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return Rate(s)
+}
+
+// Convert converts m into currency at rate, rounding half away from zero to
+// the minor unit of currency.
+func (m Money) Convert(rate Rate, currency string) (Money, error) {
+	fromUnits, err := MinorUnits(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	toUnits, err := MinorUnits(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	rat, err := rate.Rat()
+	if err != nil {
+		return Money{}, err
+	}
+
+	// minor_to = minor_from * rate * 10^(toUnits - fromUnits)
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rat)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(toUnits-fromUnits))), nil))
+	if toUnits >= fromUnits {
+		value.Mul(value, scale)
+	} else {
+		value.Quo(value, scale)
+	}
+
+	rounded := roundHalfAwayFromZero(value)
+	if !rounded.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: rounded.Int64(), Currency: currency}, nil
+}
+
+func roundHalfAwayFromZero(r *big.Rat) *big.Int {
+	num, denom := new(big.Int).Abs(r.Num()), r.Denom()
+	quo, rem := new(big.Int).QuoRem(num, denom, new(big.Int))
+	if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(denom) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	return quo
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// CurrencyList is a list of currency codes stored as comma-separated text.
+type CurrencyList []string
+
+func (l CurrencyList) Contains(code string) bool {
+	for _, c := range l {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (l CurrencyList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+func (l *CurrencyList) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("cannot scan %T into CurrencyList", value)
+	}
+
+	*l = CurrencyList{}
+	for _, code := range strings.Split(s, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			*l = append(*l, code)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	rate, err := ParseRate("1.250000")
+	assert.NoError(t, err)
+	assert.Equal(t, Rate("1.25"), rate)
+
+	for _, s := range []string{"0", "-1.2", "abc", "1/3", "1e3"} {
+		_, err := ParseRate(s)
+		assert.ErrorIs(t, err, ErrInvalidRate, s)
+	}
+}
+
+func TestMoney_Convert(t *testing.T) {
+	// 1 JPY = 0.0091 SGD: 1500 JPY -> 13.65 SGD
+	converted, err := Money{Amount: 1500, Currency: "JPY"}.Convert("0.0091", "SGD")
+	assert.NoError(t, err)
+	assert.Equal(t, Money{Amount: 1365, Currency: "SGD"}, converted)
+
+	// 1 USD = 0.376 BHD: 10.05 USD -> 3.7788 BHD, rounded to 3.779
+	converted, err = Money{Amount: 1005, Currency: "USD"}.Convert("0.376", "BHD")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3779), converted.Amount)
+
+	// halves round away from zero
+	converted, err = Money{Amount: -1, Currency: "USD"}.Convert("0.5", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), converted.Amount)
+}
+
+func TestRate_Inverse(t *testing.T) {
+	inverse, err := Rate("4").Inverse()
+	assert.NoError(t, err)
+	assert.Equal(t, Rate("0.25"), inverse)
+}
+
+func TestCurrencyList_Scan(t *testing.T) {
+	var list CurrencyList
+	assert.NoError(t, list.Scan("USD, EUR,,JPY"))
+	assert.Equal(t, CurrencyList{"USD", "EUR", "JPY"}, list)
+
+	value, err := list.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "USD,EUR,JPY", value)
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// JoinCodeAlphabet leaves out characters that are easily mistaken for one
+// another: 0 and O, 1, I and L.
+const JoinCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+const JoinCodeLength = 8
+
+// JoinCodeAttempts is how many codes NewUniqueJoinCode draws before giving up.
+const JoinCodeAttempts = 5
+
+var ErrInvalidJoinCode = errors.New("invalid join code")
+
+// NewJoinCode returns a random join code of JoinCodeLength characters.
+func NewJoinCode() (string, error) {
+	max := big.NewInt(int64(len(JoinCodeAlphabet)))
+	code := make([]byte, JoinCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = JoinCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// NewUniqueJoinCode returns a join code no room in db has yet. A clash with
+// another room's code is unlikely, but then a fresh code is drawn.
+func NewUniqueJoinCode(db *gorm.DB) (string, error) {
+	for attempt := 1; ; attempt++ {
+		code, err := NewJoinCode()
+		if err != nil {
+			return "", err
+		}
+		var taken int64
+		if err := db.Model(&Room{}).Where("join_code = ?", code).Count(&taken).Error; err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return code, nil
+		}
+		if attempt == JoinCodeAttempts {
+			return "", errors.New("no free join code")
+		}
+	}
+}
+
+// NormalizeJoinCode turns a code as typed by a user into its stored form. It
+// ignores case, spaces and dashes.
+func NormalizeJoinCode(s string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		switch {
+		case r == '-' || r == ' ':
+			continue
+		case r > 0x7f || !strings.ContainsRune(JoinCodeAlphabet, r):
+			return "", ErrInvalidJoinCode
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() != JoinCodeLength {
+		return "", ErrInvalidJoinCode
+	}
+	return b.String(), nil
+}
+
+// FormatJoinCode splits a code in two halves for display, as in ABCD-EFGH.
+func FormatJoinCode(code string) string {
+	half := len(code) / 2
+	return code[:half] + "-" + code[half:]
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJoinCode(t *testing.T) {
+	code, err := NewJoinCode()
+	assert.NoError(t, err)
+	assert.Len(t, code, JoinCodeLength)
+	for _, r := range code {
+		assert.True(t, strings.ContainsRune(JoinCodeAlphabet, r))
+	}
+
+	normalized, err := NormalizeJoinCode(FormatJoinCode(code))
+	assert.NoError(t, err)
+	assert.Equal(t, code, normalized)
+}
+
+func TestNormalizeJoinCode(t *testing.T) {
+	code, err := NormalizeJoinCode(" abcd-efgh ")
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCDEFGH", code)
+
+	code, err = NormalizeJoinCode("k7m2 p9q3")
+	assert.NoError(t, err)
+	assert.Equal(t, "K7M2P9Q3", code)
+
+	for _, s := range []string{"", "ABCDEFG", "ABCDEFGHJ", "ABCD-EFG0", "OBCDEFGH", "ABCDEFGI", "ABCDÉFGH"} {
+		_, err := NormalizeJoinCode(s)
+		assert.ErrorIs(t, err, ErrInvalidJoinCode, s)
+	}
+}
+
+func TestFormatJoinCode(t *testing.T) {
+	assert.Equal(t, "ABCD-EFGH", FormatJoinCode("ABCDEFGH"))
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONText_RoundTrip(t *testing.T) {
+	text, err := NewJSONText(map[string]int64{"amount": 1250})
+	assert.NoError(t, err)
+
+	value, err := text.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"amount":1250}`, value)
+
+	var scanned JSONText
+	assert.NoError(t, scanned.Scan([]byte(`{"amount":1250}`)))
+	data, err := json.Marshal(struct {
+		After JSONText `json:"after"`
+	}{scanned})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"after":{"amount":1250}}`, string(data))
+}
+
+func TestJSONText_Empty(t *testing.T) {
+	text, err := NewJSONText(nil)
+	assert.NoError(t, err)
+
+	value, err := text.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	data, err := json.Marshal(text)
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(data))
+
+	assert.Error(t, text.Scan(42))
+}
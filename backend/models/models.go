@@ -8,11 +8,22 @@ import (
 )
 
 type Room struct {
-	ID           uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	Name         string    `gorm:"type:text" json:"name"`
-	BaseCurrency string    `gorm:"type:text" json:"base_currency"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                  uuid.UUID    `gorm:"type:uuid;primary_key;" json:"id"`
+	Name                string       `gorm:"type:text" json:"name"`
+	BaseCurrency        string       `gorm:"type:text" json:"base_currency"`
+	ForeignCurrencies   CurrencyList `gorm:"type:text" json:"foreign_currencies"`
+	FxMode              string       `gorm:"type:text;default:LOCKED" json:"fx_mode"`
+	SimplifyAlgo        string       `gorm:"type:text" json:"simplify_algo"`
+	RequireItemApproval bool         `gorm:"default:false" json:"require_item_approval"`
+	RequireJoinApproval bool         `gorm:"default:false" json:"require_join_approval"`
+	// IsTemplate marks a room kept only to be cloned; it never holds items.
+	IsTemplate        bool       `gorm:"default:false" json:"is_template"`
+	JoinCode          *string    `gorm:"type:text;uniqueIndex;" json:"-"`
+	JoinCodeExpiresAt *time.Time `json:"-"`
+	ArchivedAt        *time.Time `gorm:"index;" json:"archived_at"`
+	ArchivedBy        *uuid.UUID `gorm:"type:uuid;" json:"archived_by"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
 }
 
 type Item struct {
@@ -21,25 +32,239 @@ type Item struct {
 	GroupID         uuid.UUID `gorm:"type:uuid;index;" json:"group_id"`
 	FromUserID      uuid.UUID `gorm:"type:uuid;index;" json:"from_user_id"`
 	ToUserID        uuid.UUID `gorm:"type:uuid;index;" json:"to_user_id"`
-	Amount          int       `gorm:"type:int;" json:"amount"`
-	ForeignAmount   int       `gorm:"type:int;" json:"foreign_amount"`
+	Amount          int64     `gorm:"type:bigint;" json:"amount"`
+	ForeignAmount   int64     `gorm:"type:bigint;" json:"foreign_amount"`
 	ForeignCurrency string    `json:"foreign_currency"`
-	Content         string    `json:"content"`
-	TransactionType string    `json:"transaction_type"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	FxRate          Rate      `gorm:"type:numeric(24,12);" json:"fx_rate,omitempty"`
+	// FxLockedAt is set once a settlement has fixed the item's rate, after
+	// which floating rates leave it alone.
+	FxLockedAt      *time.Time `json:"fx_locked_at,omitempty"`
+	Content         string     `json:"content"`
+	Category        string     `gorm:"type:text;index;" json:"category"`
+	TransactionType string     `json:"transaction_type"`
+	CreatorID       uuid.UUID  `gorm:"type:uuid;index;" json:"creator_id"`
+	Status          string     `gorm:"type:text;default:APPROVED" json:"status"`
+	DisputeReason   string     `gorm:"type:text" json:"dispute_reason,omitempty"`
+	OccurredAt      time.Time  `gorm:"index;" json:"occurred_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
+// User is either a registered account or, when PlaceholderRoomID is set, a
+// stand-in for someone without one. Placeholder names are only unique within
+// their room and they cannot log in.
 type User struct {
-	ID           uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	Name         string    `gorm:"type:text;unique" json:"name"`
-	PasswordHash string    `gorm:"type:text;" json:"password_hash"`
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	Name              string     `gorm:"type:text;uniqueIndex:idx_users_name,where:placeholder_room_id IS NULL" json:"name"`
+	PasswordHash      string     `gorm:"type:text;" json:"-"`
+	PlaceholderRoomID *uuid.UUID `gorm:"type:uuid;index;" json:"placeholder_room_id,omitempty"`
+	// Email is only shown to the user themselves, through GetAccount.
+	Email *string `gorm:"type:text;uniqueIndex;" json:"-"`
+}
+
+// PasswordReset is a single-use token for setting a forgotten password. Only
+// its hash is stored.
+type PasswordReset struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index;" json:"user_id"`
+	TokenHash string     `gorm:"type:text;uniqueIndex;" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Session is a login on one device. The refresh token is kept only as a
+// hash; the one it replaced is kept too, so that a reused token revokes the
+// session.
+type Session struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	UserID            uuid.UUID  `gorm:"type:uuid;index;" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"type:text;uniqueIndex;" json:"-"`
+	PreviousTokenHash string     `gorm:"type:text;index;" json:"-"`
+	UserAgent         string     `gorm:"type:text" json:"user_agent"`
+	IPAddress         string     `gorm:"type:text" json:"ip_address"`
+	LastSeenAt        time.Time  `json:"last_seen_at"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
 }
 
 type RoomUser struct {
 	RoomID uuid.UUID `gorm:"type:uuid;primary_key;" json:"room_id"`
 	UserID uuid.UUID `gorm:"type:uuid;index;" json:"user_id"`
 	Status string    `json:"status"`
+	Role   string    `gorm:"type:text;default:MEMBER" json:"role"`
+	// HouseholdID is the household the member splits and settles with.
+	HouseholdID *uuid.UUID `gorm:"type:uuid;index;" json:"household_id"`
+}
+
+// Invite lets users join a room through a signed link until it expires, is
+// used MaxUses times (0 for unlimited) or is revoked.
+type Invite struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomID    uuid.UUID  `gorm:"type:uuid;index;" json:"room_id"`
+	CreatorID uuid.UUID  `gorm:"type:uuid;" json:"creator_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	// PlaceholderID makes the invite hand a placeholder's history over to
+	// whoever redeems it.
+	PlaceholderID *uuid.UUID `gorm:"type:uuid;" json:"placeholder_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Household is a named group of room members who split and settle as one.
+type Household struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomID    uuid.UUID   `gorm:"type:uuid;index;" json:"room_id"`
+	Name      string      `gorm:"type:text" json:"name"`
+	MemberIDs []uuid.UUID `gorm:"-" json:"member_ids"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// RoomAuditEntry is one entry of a room's append-only audit log. A change to
+// a setting names the Field with its old and new value; any other action
+// holds the affected records in Before and After as they were and became.
+type RoomAuditEntry struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomID    uuid.UUID `gorm:"type:uuid;index;index:idx_room_audit_entries_room_created;" json:"room_id"`
+	ActorID   uuid.UUID `gorm:"type:uuid;index;" json:"actor_id"`
+	Action    string    `gorm:"type:text;index;" json:"action"`
+	Field     string    `gorm:"type:text" json:"field,omitempty"`
+	OldValue  string    `gorm:"type:text" json:"old_value,omitempty"`
+	NewValue  string    `gorm:"type:text" json:"new_value,omitempty"`
+	Before    JSONText  `gorm:"type:text" json:"before,omitempty"`
+	After     JSONText  `gorm:"type:text" json:"after,omitempty"`
+	CreatedAt time.Time `gorm:"index:idx_room_audit_entries_room_created;" json:"created_at"`
+}
+
+type ExchangeRate struct {
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomID   uuid.UUID `gorm:"type:uuid;index:idx_exchange_rates_lookup;" json:"room_id"`
+	Currency string    `gorm:"type:text;index:idx_exchange_rates_lookup;" json:"currency"`
+	// BaseCurrency is the room's base currency when the rate was recorded.
+	BaseCurrency string    `gorm:"type:text;" json:"base_currency"`
+	Rate         Rate      `gorm:"type:numeric(24,12);" json:"rate"`
+	EffectiveAt  time.Time `gorm:"index:idx_exchange_rates_lookup;" json:"effective_at"`
+	CreatorID    uuid.UUID `gorm:"type:uuid;" json:"creator_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Revaluation records a change of a room's base currency. Entries keep every
+// item's previous amounts so the change can be reverted.
+type Revaluation struct {
+	ID                   uuid.UUID                `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomID               uuid.UUID                `gorm:"type:uuid;index;" json:"room_id"`
+	FromCurrency         string                   `gorm:"type:text" json:"from_currency"`
+	ToCurrency           string                   `gorm:"type:text" json:"to_currency"`
+	RateDate             string                   `gorm:"type:text" json:"rate_date"`
+	OldForeignCurrencies CurrencyList             `gorm:"type:text" json:"old_foreign_currencies"`
+	CreatorID            uuid.UUID                `gorm:"type:uuid;" json:"creator_id"`
+	RevertedAt           *time.Time               `json:"reverted_at"`
+	RevertedBy           *uuid.UUID               `gorm:"type:uuid;" json:"reverted_by"`
+	CreatedAt            time.Time                `json:"created_at"`
+	Entries              []RevaluationEntry       `json:"entries,omitempty"`
+	Budgets              []RevaluationBudget      `json:"budgets,omitempty"`
+	BudgetAlerts         []RevaluationBudgetAlert `json:"budget_alerts,omitempty"`
+}
+
+type RevaluationEntry struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	RevaluationID      uuid.UUID `gorm:"type:uuid;index;" json:"revaluation_id"`
+	ItemID             uuid.UUID `gorm:"type:uuid;" json:"item_id"`
+	OldAmount          int64     `gorm:"type:bigint;" json:"old_amount"`
+	OldForeignAmount   int64     `gorm:"type:bigint;" json:"old_foreign_amount"`
+	OldForeignCurrency string    `gorm:"type:text" json:"old_foreign_currency"`
+	OldFxRate          Rate      `gorm:"type:numeric(24,12);" json:"old_fx_rate,omitempty"`
+	NewAmount          int64     `gorm:"type:bigint;" json:"new_amount"`
+	NewFxRate          Rate      `gorm:"type:numeric(24,12);" json:"new_fx_rate,omitempty"`
+}
+
+// RevaluationBudget records a budget's amount before and after a revaluation.
+type RevaluationBudget struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	RevaluationID uuid.UUID `gorm:"type:uuid;index;" json:"revaluation_id"`
+	BudgetID      uuid.UUID `gorm:"type:uuid;" json:"budget_id"`
+	OldAmount     int64     `gorm:"type:bigint;" json:"old_amount"`
+	NewAmount     int64     `gorm:"type:bigint;" json:"new_amount"`
+}
+
+// RevaluationBudgetAlert records the amounts of a budget alert before and
+// after a revaluation.
+type RevaluationBudgetAlert struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	RevaluationID uuid.UUID `gorm:"type:uuid;index;" json:"revaluation_id"`
+	BudgetAlertID uuid.UUID `gorm:"type:uuid;" json:"budget_alert_id"`
+	OldSpent      int64     `gorm:"type:bigint;" json:"old_spent"`
+	OldBudgeted   int64     `gorm:"type:bigint;" json:"old_budgeted"`
+	NewSpent      int64     `gorm:"type:bigint;" json:"new_spent"`
+	NewBudgeted   int64     `gorm:"type:bigint;" json:"new_budgeted"`
+}
+
+// RoomSnapshot freezes a room's balances and settlement plan at the time it
+// was archived.
+type RoomSnapshot struct {
+	ID          uuid.UUID            `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomID      uuid.UUID            `gorm:"type:uuid;index;" json:"room_id"`
+	Currency    string               `gorm:"type:text" json:"currency"`
+	CreatorID   uuid.UUID            `gorm:"type:uuid;" json:"creator_id"`
+	CreatedAt   time.Time            `json:"created_at"`
+	Balances    []SnapshotBalance    `json:"balances"`
+	Settlements []SnapshotSettlement `json:"settlements"`
+}
+
+type SnapshotBalance struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomSnapshotID uuid.UUID `gorm:"type:uuid;index;" json:"room_snapshot_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;" json:"user_id"`
+	Balance        int64     `gorm:"type:bigint;" json:"balance"`
+}
+
+type SnapshotSettlement struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomSnapshotID uuid.UUID `gorm:"type:uuid;index;" json:"room_snapshot_id"`
+	FromUserID     uuid.UUID `gorm:"type:uuid;" json:"from_user_id"`
+	ToUserID       uuid.UUID `gorm:"type:uuid;" json:"to_user_id"`
+	Amount         int64     `gorm:"type:bigint;" json:"amount"`
+}
+
+type Attachment struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomID       uuid.UUID `gorm:"type:uuid;index;" json:"room_id"`
+	GroupID      uuid.UUID `gorm:"type:uuid;index;" json:"group_id"`
+	UploaderID   uuid.UUID `gorm:"type:uuid;" json:"uploader_id"`
+	FileName     string    `gorm:"type:text" json:"file_name"`
+	ContentType  string    `gorm:"type:text" json:"content_type"`
+	Size         int64     `json:"size"`
+	StorageKey   string    `gorm:"type:text" json:"-"`
+	ThumbnailKey string    `gorm:"type:text" json:"-"`
+	HasThumbnail bool      `json:"has_thumbnail"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Budget struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomID    uuid.UUID  `gorm:"type:uuid;index;" json:"room_id"`
+	Name      string     `gorm:"type:text" json:"name"`
+	Category  string     `gorm:"type:text" json:"category"`
+	UserID    *uuid.UUID `gorm:"type:uuid;" json:"user_id"`
+	Period    string     `gorm:"type:text" json:"period"`
+	Amount    int64      `gorm:"type:bigint;" json:"amount"`
+	CreatorID uuid.UUID  `gorm:"type:uuid;" json:"creator_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type BudgetAlert struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	RoomID      uuid.UUID `gorm:"type:uuid;index;" json:"room_id"`
+	BudgetID    uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_budget_alert_period;" json:"budget_id"`
+	Threshold   int       `gorm:"uniqueIndex:idx_budget_alert_period;" json:"threshold"`
+	PeriodStart time.Time `gorm:"uniqueIndex:idx_budget_alert_period;" json:"period_start"`
+	Spent       int64     `gorm:"type:bigint;" json:"spent"`
+	Budgeted    int64     `gorm:"type:bigint;" json:"budgeted"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type SimplifiedItem struct {
@@ -47,7 +272,7 @@ type SimplifiedItem struct {
 	RoomID     uuid.UUID `gorm:"type:uuid;index;" json:"room_id"`
 	FromUserID uuid.UUID `gorm:"type:uuid;index;" json:"from_user_id"`
 	ToUserID   uuid.UUID `gorm:"type:uuid;index;" json:"to_user_id"`
-	Amount     int       `gorm:"type:int;" json:"amount"`
+	Amount     int64     `gorm:"type:bigint;" json:"amount"`
 }
 
 func (r *Room) BeforeCreate(tx *gorm.DB) (err error) {
@@ -57,6 +282,9 @@ func (r *Room) BeforeCreate(tx *gorm.DB) (err error) {
 
 func (i *Item) BeforeCreate(tx *gorm.DB) (err error) {
 	i.ID = uuid.New()
+	if i.OccurredAt.IsZero() {
+		i.OccurredAt = time.Now()
+	}
 	return
 }
 
@@ -65,7 +293,87 @@ func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (e *ExchangeRate) BeforeCreate(tx *gorm.DB) (err error) {
+	e.ID = uuid.New()
+	return
+}
+
+func (a *Attachment) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return
+}
+
+func (b *Budget) BeforeCreate(tx *gorm.DB) (err error) {
+	b.ID = uuid.New()
+	return
+}
+
+func (a *BudgetAlert) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return
+}
+
 func (u *SimplifiedItem) BeforeCreate(tx *gorm.DB) (err error) {
 	u.ID = uuid.New()
 	return
 }
+
+func (r *Revaluation) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ID = uuid.New()
+	return
+}
+
+func (e *RevaluationEntry) BeforeCreate(tx *gorm.DB) (err error) {
+	e.ID = uuid.New()
+	return
+}
+
+func (b *RevaluationBudget) BeforeCreate(tx *gorm.DB) (err error) {
+	b.ID = uuid.New()
+	return
+}
+
+func (a *RevaluationBudgetAlert) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return
+}
+
+func (i *Invite) BeforeCreate(tx *gorm.DB) (err error) {
+	i.ID = uuid.New()
+	return
+}
+
+func (e *RoomAuditEntry) BeforeCreate(tx *gorm.DB) (err error) {
+	e.ID = uuid.New()
+	return
+}
+
+func (s *RoomSnapshot) BeforeCreate(tx *gorm.DB) (err error) {
+	s.ID = uuid.New()
+	return
+}
+
+func (b *SnapshotBalance) BeforeCreate(tx *gorm.DB) (err error) {
+	b.ID = uuid.New()
+	return
+}
+
+func (s *SnapshotSettlement) BeforeCreate(tx *gorm.DB) (err error) {
+	s.ID = uuid.New()
+	return
+}
+
+func (p *PasswordReset) BeforeCreate(tx *gorm.DB) (err error) {
+	p.ID = uuid.New()
+	return
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) (err error) {
+	s.ID = uuid.New()
+	return
+}
+
+func (h *Household) BeforeCreate(tx *gorm.DB) (err error) {
+	h.ID = uuid.New()
+	return
+}
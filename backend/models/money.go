@@ -0,0 +1,244 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrMoneyOverflow    = errors.New("money overflow")
+	ErrInvalidAmount    = errors.New("invalid amount")
+)
+
+// Money is an amount in the minor units (e.g. cents) of an ISO 4217 currency.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// currencyMinorUnits lists the active ISO 4217 codes with the number of
+// decimal places of their minor unit.
+var currencyMinorUnits = map[string]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2,
+	"AWG": 2, "AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0,
+	"BMD": 2, "BND": 2, "BOB": 2, "BRL": 2, "BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2,
+	"BZD": 2, "CAD": 2, "CDF": 2, "CHF": 2, "CLF": 4, "CLP": 0, "CNY": 2, "COP": 2,
+	"CRC": 2, "CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2,
+	"EGP": 2, "ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2,
+	"GHS": 2, "GIP": 2, "GMD": 2, "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2,
+	"HTG": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "IQD": 3, "IRR": 2, "ISK": 0,
+	"JMD": 2, "JOD": 3, "JPY": 0, "KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2,
+	"KRW": 0, "KWD": 3, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2,
+	"LSL": 2, "LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2,
+	"MOP": 2, "MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MYR": 2, "MZN": 2,
+	"NAD": 2, "NGN": 2, "NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2,
+	"PEN": 2, "PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0, "QAR": 2, "RON": 2,
+	"RSD": 2, "RUB": 2, "RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2,
+	"SGD": 2, "SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2,
+	"SYP": 2, "SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2,
+	"TTD": 2, "TWD": 2, "TZS": 2, "UAH": 2, "UGX": 0, "USD": 2, "UYI": 0, "UYU": 2,
+	"UYW": 4, "UZS": 2, "VED": 2, "VES": 2, "VND": 0, "VUV": 0, "WST": 2, "XAF": 0,
+	"XCD": 2, "XOF": 0, "XPF": 0, "YER": 2, "ZAR": 2, "ZMW": 2, "ZWG": 2,
+}
+
+// ValidateCurrency returns ErrUnknownCurrency unless code is an ISO 4217 code.
+// Codes are expected in upper case.
+func ValidateCurrency(code string) error {
+	if _, ok := currencyMinorUnits[code]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return nil
+}
+
+// MinorUnits returns the number of decimal places used by currency.
+func MinorUnits(currency string) (int, error) {
+	units, ok := currencyMinorUnits[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return units, nil
+}
+
+func NewMoney(amount int64, currency string) (Money, error) {
+	if err := ValidateCurrency(currency); err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// ParseMoney parses a decimal string such as "12.34" or "-5" in currency.
+// More fractional digits than the currency has minor units is an error.
+func ParseMoney(s string, currency string) (Money, error) {
+	units, err := MinorUnits(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || len(frac) > units || strings.ContainsAny(whole+frac, "+-") {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	frac += strings.Repeat("0", units-len(frac))
+
+	digits := whole + frac
+	if digits == "" {
+		digits = "0"
+	}
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Money{}, ErrMoneyOverflow
+		}
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum, ok := addInt64(m.Amount, other.Amount)
+	if !ok {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	neg, err := other.Neg()
+	if err != nil {
+		return Money{}, err
+	}
+	return m.Add(neg)
+}
+
+func (m Money) Neg() (Money, error) {
+	if m.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: -m.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Mul(factor int64) (Money, error) {
+	product, ok := mulInt64(m.Amount, factor)
+	if !ok {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Split divides m into n parts that differ by at most one minor unit and sum
+// back to m exactly. The earlier parts receive the remainder.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	share, remainder := m.Amount/int64(n), m.Amount%int64(n)
+	parts := make([]Money, n)
+	for i := range parts {
+		parts[i] = Money{Amount: share, Currency: m.Currency}
+		if remainder > 0 {
+			parts[i].Amount++
+			remainder--
+		} else if remainder < 0 {
+			parts[i].Amount--
+			remainder++
+		}
+	}
+	return parts, nil
+}
+
+// String formats m as a plain decimal string, e.g. "1234.50".
+func (m Money) String() string {
+	units := currencyMinorUnits[m.Currency]
+	negative := m.Amount < 0
+
+	// work on the unsigned magnitude so MinInt64 formats correctly
+	magnitude := uint64(m.Amount)
+	if negative {
+		magnitude = -magnitude
+	}
+	digits := strconv.FormatUint(magnitude, 10)
+	if units > 0 {
+		if len(digits) <= units {
+			digits = strings.Repeat("0", units-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-units] + "." + digits[len(digits)-units:]
+	}
+	if negative {
+		digits = "-" + digits
+	}
+	return digits
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount, _ := json.Marshal(m.String())
+	return json.Marshal(moneyJSON{Amount: amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON accepts the amount as a decimal string, or as a JSON number
+// for clients that cannot send strings.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var amount string
+	if err := json.Unmarshal(raw.Amount, &amount); err != nil {
+		var number json.Number
+		if err := json.Unmarshal(raw.Amount, &number); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidAmount, raw.Amount)
+		}
+		amount = number.String()
+	}
+
+	parsed, err := ParseMoney(amount, strings.ToUpper(raw.Currency))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func addInt64(a int64, b int64) (int64, bool) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, false
+	}
+	return sum, true
+}
+
+func mulInt64(a int64, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney_MinorUnits(t *testing.T) {
+	m, err := ParseMoney("12.34", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1234), m.Amount)
+
+	m, err = ParseMoney("1500", "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1500), m.Amount)
+
+	m, err = ParseMoney("-1.5", "BHD")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1500), m.Amount)
+
+	_, err = ParseMoney("1.5", "JPY")
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = ParseMoney("1.00", "XYZ")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "0.05", Money{Amount: 5, Currency: "EUR"}.String())
+	assert.Equal(t, "-12.30", Money{Amount: -1230, Currency: "EUR"}.String())
+	assert.Equal(t, "1.234", Money{Amount: 1234, Currency: "KWD"}.String())
+	assert.Equal(t, "700", Money{Amount: 700, Currency: "KRW"}.String())
+	assert.Equal(t, "-92233720368547758.08", Money{Amount: math.MinInt64, Currency: "USD"}.String())
+}
+
+func TestMoney_CheckedArithmetic(t *testing.T) {
+	a := Money{Amount: math.MaxInt64, Currency: "USD"}
+
+	_, err := a.Add(Money{Amount: 1, Currency: "USD"})
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+
+	_, err = a.Add(Money{Amount: 1, Currency: "EUR"})
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = a.Mul(2)
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+
+	_, err = Money{Amount: math.MinInt64, Currency: "USD"}.Neg()
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+
+	diff, err := Money{Amount: 500, Currency: "USD"}.Sub(Money{Amount: 800, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-300), diff.Amount)
+}
+
+func TestMoney_Split(t *testing.T) {
+	parts, err := Money{Amount: 1000, Currency: "USD"}.Split(3)
+	assert.NoError(t, err)
+
+	var total int64
+	for _, part := range parts {
+		total += part.Amount
+	}
+	assert.Equal(t, int64(1000), total)
+	assert.Equal(t, int64(334), parts[0].Amount)
+	assert.Equal(t, int64(333), parts[2].Amount)
+}
+
+func TestMoney_JSONRoundTrip(t *testing.T) {
+	m := Money{Amount: 123456, Currency: "EUR"}
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"1234.56","currency":"EUR"}`, string(data))
+
+	var decoded Money
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, m, decoded)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":9.5,"currency":"usd"}`), &decoded))
+	assert.Equal(t, Money{Amount: 950, Currency: "USD"}, decoded)
+}
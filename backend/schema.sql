@@ -2,24 +2,110 @@ CREATE TABLE rooms (
                        id UUID PRIMARY KEY,
                        name TEXT NOT NULL,
                        base_currency TEXT,
+                       require_item_approval BOOLEAN NOT NULL DEFAULT FALSE,
+                       require_join_approval BOOLEAN NOT NULL DEFAULT FALSE,
                        foreign_currencies TEXT,
+                       fx_mode TEXT NOT NULL DEFAULT 'LOCKED',
+                       simplify_algo TEXT,
+                       is_template BOOLEAN NOT NULL DEFAULT FALSE,
+                       join_code TEXT UNIQUE,
+                       join_code_expires_at TIMESTAMP,
+                       archived_at TIMESTAMP,
+                       archived_by UUID,
                        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
                        updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE TABLE users (
                        id UUID PRIMARY KEY,
-                       name TEXT NOT NULL UNIQUE,
-                       password_hash TEXT NOT NULL
+                       name TEXT NOT NULL,
+                       password_hash TEXT NOT NULL,
+                       placeholder_room_id UUID REFERENCES rooms(id) ON DELETE CASCADE,
+                       email TEXT UNIQUE
+);
+
+CREATE UNIQUE INDEX idx_users_name ON users(name) WHERE placeholder_room_id IS NULL;
+CREATE INDEX idx_users_placeholder_room_id ON users(placeholder_room_id);
+
+CREATE TABLE households (
+                        id UUID PRIMARY KEY,
+                        room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+                        name TEXT NOT NULL,
+                        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_households_room_id ON households(room_id);
+
+CREATE TABLE password_resets (
+                        id UUID PRIMARY KEY,
+                        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                        token_hash TEXT NOT NULL UNIQUE,
+                        expires_at TIMESTAMP NOT NULL,
+                        used_at TIMESTAMP,
+                        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_password_resets_user_id ON password_resets(user_id);
+
+CREATE TABLE sessions (
+                        id UUID PRIMARY KEY,
+                        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                        refresh_token_hash TEXT NOT NULL UNIQUE,
+                        previous_token_hash TEXT,
+                        user_agent TEXT,
+                        ip_address TEXT,
+                        last_seen_at TIMESTAMP NOT NULL,
+                        expires_at TIMESTAMP NOT NULL,
+                        revoked_at TIMESTAMP,
+                        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_sessions_previous_token_hash ON sessions(previous_token_hash);
+
 CREATE TABLE room_users (
                         room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
                         user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                        status TEXT,
+                        role TEXT NOT NULL DEFAULT 'MEMBER',
+                        household_id UUID REFERENCES households(id) ON DELETE SET NULL,
                         PRIMARY KEY (room_id, user_id)
 );
 
 CREATE INDEX idx_room_users_room_id ON room_users(room_id);
+CREATE INDEX idx_room_users_household_id ON room_users(household_id);
+
+CREATE TABLE invites (
+                        id UUID PRIMARY KEY,
+                        room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+                        creator_id UUID REFERENCES users(id),
+                        expires_at TIMESTAMP,
+                        max_uses INTEGER NOT NULL DEFAULT 0,
+                        uses INTEGER NOT NULL DEFAULT 0,
+                        revoked_at TIMESTAMP,
+                        placeholder_id UUID REFERENCES users(id) ON DELETE SET NULL,
+                        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_invites_room_id ON invites(room_id);
+
+CREATE TABLE room_audit_entries (
+                        id UUID PRIMARY KEY,
+                        room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+                        actor_id UUID REFERENCES users(id),
+                        action TEXT NOT NULL,
+                        field TEXT NOT NULL DEFAULT '',
+                        old_value TEXT,
+                        new_value TEXT,
+                        before TEXT,
+                        after TEXT,
+                        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_room_audit_entries_room_id ON room_audit_entries(room_id);
+CREATE INDEX idx_room_audit_entries_room_created ON room_audit_entries(room_id, created_at);
+CREATE INDEX idx_room_audit_entries_actor_id ON room_audit_entries(actor_id);
+CREATE INDEX idx_room_audit_entries_action ON room_audit_entries(action);
 
 CREATE TABLE items (
                        id UUID PRIMARY KEY,
@@ -27,11 +113,18 @@ CREATE TABLE items (
                        group_id UUID,
                        from_user_id UUID NOT NULL REFERENCES users(id),
                        to_user_id UUID NOT NULL REFERENCES users(id),
-                       amount INTEGER NOT NULL,
-                       foreign_amount INTEGER,
+                       amount BIGINT NOT NULL,
+                       foreign_amount BIGINT,
                        foreign_currency TEXT,
+                       fx_rate NUMERIC(24,12),
+                       fx_locked_at TIMESTAMP,
                        content TEXT NOT NULL,
+                       category TEXT,
                        transaction_type TEXT NOT NULL,
+                       creator_id UUID REFERENCES users(id),
+                       status TEXT NOT NULL DEFAULT 'APPROVED',
+                       dispute_reason TEXT,
+                       occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
                        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
                        updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
@@ -39,3 +132,142 @@ CREATE TABLE items (
 CREATE INDEX idx_items_room_id ON items(room_id);
 CREATE INDEX idx_items_from_user_id ON items(from_user_id);
 CREATE INDEX idx_items_to_user_id ON items(to_user_id);
+
+CREATE TABLE attachments (
+                       id UUID PRIMARY KEY,
+                       room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+                       group_id UUID NOT NULL,
+                       uploader_id UUID NOT NULL REFERENCES users(id),
+                       file_name TEXT NOT NULL,
+                       content_type TEXT NOT NULL,
+                       size BIGINT NOT NULL,
+                       storage_key TEXT NOT NULL,
+                       thumbnail_key TEXT,
+                       has_thumbnail BOOLEAN NOT NULL DEFAULT FALSE,
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_attachments_group_id ON attachments(group_id);
+
+CREATE INDEX idx_items_room_id_occurred_at ON items(room_id, occurred_at, id);
+CREATE INDEX idx_items_content_fts ON items USING GIN (to_tsvector('simple', content));
+
+CREATE TABLE budgets (
+                       id UUID PRIMARY KEY,
+                       room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+                       name TEXT NOT NULL,
+                       category TEXT,
+                       user_id UUID REFERENCES users(id),
+                       period TEXT NOT NULL,
+                       amount BIGINT NOT NULL,
+                       creator_id UUID REFERENCES users(id),
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_budgets_room_id ON budgets(room_id);
+
+CREATE TABLE budget_alerts (
+                       id UUID PRIMARY KEY,
+                       room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+                       budget_id UUID NOT NULL REFERENCES budgets(id) ON DELETE CASCADE,
+                       threshold INTEGER NOT NULL,
+                       period_start TIMESTAMP NOT NULL,
+                       spent BIGINT NOT NULL,
+                       budgeted BIGINT NOT NULL,
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       UNIQUE (budget_id, threshold, period_start)
+);
+
+CREATE TABLE exchange_rates (
+                       id UUID PRIMARY KEY,
+                       room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+                       currency TEXT NOT NULL,
+                       base_currency TEXT NOT NULL,
+                       rate NUMERIC(24,12) NOT NULL,
+                       effective_at TIMESTAMP NOT NULL,
+                       creator_id UUID REFERENCES users(id),
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_exchange_rates_lookup ON exchange_rates(room_id, currency, effective_at);
+
+CREATE TABLE revaluations (
+                       id UUID PRIMARY KEY,
+                       room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+                       from_currency TEXT NOT NULL,
+                       to_currency TEXT NOT NULL,
+                       rate_date TEXT NOT NULL,
+                       old_foreign_currencies TEXT,
+                       creator_id UUID REFERENCES users(id),
+                       reverted_at TIMESTAMP,
+                       reverted_by UUID REFERENCES users(id),
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_revaluations_room_id ON revaluations(room_id);
+
+CREATE TABLE revaluation_entries (
+                       id UUID PRIMARY KEY,
+                       revaluation_id UUID NOT NULL REFERENCES revaluations(id) ON DELETE CASCADE,
+                       item_id UUID NOT NULL,
+                       old_amount BIGINT NOT NULL,
+                       old_foreign_amount BIGINT,
+                       old_foreign_currency TEXT,
+                       old_fx_rate NUMERIC(24,12),
+                       new_amount BIGINT NOT NULL,
+                       new_fx_rate NUMERIC(24,12)
+);
+
+CREATE INDEX idx_revaluation_entries_revaluation_id ON revaluation_entries(revaluation_id);
+
+CREATE TABLE revaluation_budgets (
+                       id UUID PRIMARY KEY,
+                       revaluation_id UUID NOT NULL REFERENCES revaluations(id) ON DELETE CASCADE,
+                       budget_id UUID NOT NULL,
+                       old_amount BIGINT NOT NULL,
+                       new_amount BIGINT NOT NULL
+);
+
+CREATE INDEX idx_revaluation_budgets_revaluation_id ON revaluation_budgets(revaluation_id);
+
+CREATE TABLE revaluation_budget_alerts (
+                       id UUID PRIMARY KEY,
+                       revaluation_id UUID NOT NULL REFERENCES revaluations(id) ON DELETE CASCADE,
+                       budget_alert_id UUID NOT NULL,
+                       old_spent BIGINT NOT NULL,
+                       old_budgeted BIGINT NOT NULL,
+                       new_spent BIGINT NOT NULL,
+                       new_budgeted BIGINT NOT NULL
+);
+
+CREATE INDEX idx_revaluation_budget_alerts_revaluation_id ON revaluation_budget_alerts(revaluation_id);
+
+CREATE TABLE room_snapshots (
+                       id UUID PRIMARY KEY,
+                       room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+                       currency TEXT,
+                       creator_id UUID REFERENCES users(id),
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_room_snapshots_room_id ON room_snapshots(room_id);
+
+CREATE TABLE snapshot_balances (
+                       id UUID PRIMARY KEY,
+                       room_snapshot_id UUID NOT NULL REFERENCES room_snapshots(id) ON DELETE CASCADE,
+                       user_id UUID NOT NULL REFERENCES users(id),
+                       balance BIGINT NOT NULL
+);
+
+CREATE INDEX idx_snapshot_balances_room_snapshot_id ON snapshot_balances(room_snapshot_id);
+
+CREATE TABLE snapshot_settlements (
+                       id UUID PRIMARY KEY,
+                       room_snapshot_id UUID NOT NULL REFERENCES room_snapshots(id) ON DELETE CASCADE,
+                       from_user_id UUID NOT NULL REFERENCES users(id),
+                       to_user_id UUID NOT NULL REFERENCES users(id),
+                       amount BIGINT NOT NULL
+);
+
+CREATE INDEX idx_snapshot_settlements_room_snapshot_id ON snapshot_settlements(room_snapshot_id);
@@ -0,0 +1,209 @@
+package stats
+
+import (
+	"backend/models"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Interval int
+
+const (
+	Daily Interval = iota
+	Weekly
+)
+
+const LargestExpensesPerMember = 5
+
+// MaxSeriesPoints bounds a balance series to about a year of days; longer
+// histories keep only their most recent buckets.
+const MaxSeriesPoints = 366
+
+// expense mirrors handlers.Expense, which cannot be imported from here.
+const expense = "EXPENSE"
+
+type MemberStats struct {
+	UserID          uuid.UUID        `json:"user_id"`
+	TotalPaid       int64            `json:"total_paid"`
+	TotalConsumed   int64            `json:"total_consumed"`
+	NetBalance      int64            `json:"net_balance"`
+	SpendingShare   float64          `json:"spending_share"`
+	LargestExpenses []ExpenseSummary `json:"largest_expenses"`
+	BalanceSeries   []BalancePoint   `json:"balance_series"`
+}
+
+// ExpenseSummary is an expense group as seen by the member who paid for it.
+type ExpenseSummary struct {
+	GroupID    uuid.UUID `json:"group_id"`
+	Content    string    `json:"content"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Amount     int64     `json:"amount"`
+}
+
+// BalancePoint is a member's cumulative net balance at the end of the bucket
+// starting at Date.
+type BalancePoint struct {
+	Date    time.Time `json:"date"`
+	Balance int64     `json:"balance"`
+}
+
+func ParseInterval(s string) (Interval, bool) {
+	switch s {
+	case "", "daily":
+		return Daily, true
+	case "weekly":
+		return Weekly, true
+	}
+	return Daily, false
+}
+
+// Compute derives per-member statistics from a room's items. Every ID in
+// memberIDs gets an entry even without activity; anyone else appearing in
+// items is included too. Paid and consumed only count expenses, while the
+// net balance counts every item just like simplification does.
+func Compute(items []models.Item, memberIDs []uuid.UUID, interval Interval) []MemberStats {
+	byUser := map[uuid.UUID]*MemberStats{}
+	get := func(userID uuid.UUID) *MemberStats {
+		s, ok := byUser[userID]
+		if !ok {
+			s = &MemberStats{UserID: userID, LargestExpenses: []ExpenseSummary{}, BalanceSeries: []BalancePoint{}}
+			byUser[userID] = s
+		}
+		return s
+	}
+	for _, userID := range memberIDs {
+		get(userID)
+	}
+
+	sorted := make([]models.Item, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].OccurredAt.Before(sorted[j].OccurredAt) })
+
+	var totalSpending int64
+	type groupKey struct {
+		payer   uuid.UUID
+		groupID uuid.UUID
+	}
+	groups := map[groupKey]*ExpenseSummary{}
+	deltas := map[uuid.UUID]map[time.Time]int64{}
+	buckets := map[time.Time]struct{}{}
+
+	for _, item := range sorted {
+		payer, consumer := get(item.ToUserID), get(item.FromUserID)
+
+		if item.TransactionType == expense {
+			payer.TotalPaid += item.Amount
+			consumer.TotalConsumed += item.Amount
+			totalSpending += item.Amount
+
+			key := groupKey{payer: item.ToUserID, groupID: item.GroupID}
+			if _, ok := groups[key]; !ok {
+				groups[key] = &ExpenseSummary{GroupID: item.GroupID, Content: item.Content, OccurredAt: item.OccurredAt}
+			}
+			groups[key].Amount += item.Amount
+		}
+
+		bucket := BucketStart(interval, item.OccurredAt)
+		buckets[bucket] = struct{}{}
+		if item.FromUserID == item.ToUserID {
+			continue
+		}
+		payer.NetBalance += item.Amount
+		consumer.NetBalance -= item.Amount
+		addDelta(deltas, item.ToUserID, bucket, item.Amount)
+		addDelta(deltas, item.FromUserID, bucket, -item.Amount)
+	}
+
+	for key, summary := range groups {
+		s := byUser[key.payer]
+		s.LargestExpenses = append(s.LargestExpenses, *summary)
+	}
+
+	series := seriesBuckets(buckets, interval)
+	res := make([]MemberStats, 0, len(byUser))
+	for userID, s := range byUser {
+		if totalSpending > 0 {
+			s.SpendingShare = float64(s.TotalConsumed) / float64(totalSpending)
+		}
+
+		sort.Slice(s.LargestExpenses, func(i, j int) bool {
+			if s.LargestExpenses[i].Amount != s.LargestExpenses[j].Amount {
+				return s.LargestExpenses[i].Amount > s.LargestExpenses[j].Amount
+			}
+			return s.LargestExpenses[i].OccurredAt.Before(s.LargestExpenses[j].OccurredAt)
+		})
+		if len(s.LargestExpenses) > LargestExpensesPerMember {
+			s.LargestExpenses = s.LargestExpenses[:LargestExpensesPerMember]
+		}
+
+		// the series opens with whatever built up before its first bucket
+		var balance int64
+		if len(series) > 0 {
+			for bucket, delta := range deltas[userID] {
+				if bucket.Before(series[0]) {
+					balance += delta
+				}
+			}
+		}
+		for _, bucket := range series {
+			balance += deltas[userID][bucket]
+			s.BalanceSeries = append(s.BalanceSeries, BalancePoint{Date: bucket, Balance: balance})
+		}
+
+		res = append(res, *s)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].UserID.String() < res[j].UserID.String() })
+	return res
+}
+
+func addDelta(deltas map[uuid.UUID]map[time.Time]int64, userID uuid.UUID, bucket time.Time, amount int64) {
+	if deltas[userID] == nil {
+		deltas[userID] = map[time.Time]int64{}
+	}
+	deltas[userID][bucket] += amount
+}
+
+// BucketStart truncates t (in UTC) to the start of its day, or of its week
+// for Weekly buckets. Weeks start on Monday.
+func BucketStart(interval Interval, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if interval == Weekly {
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+// seriesBuckets returns every bucket between the earliest and latest used
+// bucket, so that series have no gaps, up to the last MaxSeriesPoints.
+func seriesBuckets(used map[time.Time]struct{}, interval Interval) []time.Time {
+	if len(used) == 0 {
+		return nil
+	}
+
+	var first, last time.Time
+	for bucket := range used {
+		if first.IsZero() || bucket.Before(first) {
+			first = bucket
+		}
+		if bucket.After(last) {
+			last = bucket
+		}
+	}
+
+	step := 1
+	if interval == Weekly {
+		step = 7
+	}
+	if earliest := last.AddDate(0, 0, -step*(MaxSeriesPoints-1)); first.Before(earliest) {
+		first = earliest
+	}
+	res := []time.Time{}
+	for bucket := first; !bucket.After(last); bucket = bucket.AddDate(0, 0, step) {
+		res = append(res, bucket)
+	}
+	return res
+}
@@ -0,0 +1,74 @@
+package stats
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompute(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	dinner, taxi := uuid.New(), uuid.New()
+	day1 := time.Date(2024, 3, 1, 19, 0, 0, 0, time.UTC)
+	day3 := day1.AddDate(0, 0, 2)
+
+	items := []models.Item{
+		// alice pays 100 for dinner, split evenly
+		{GroupID: dinner, FromUserID: alice, ToUserID: alice, Amount: 50, TransactionType: "EXPENSE", OccurredAt: day1},
+		{GroupID: dinner, FromUserID: bob, ToUserID: alice, Amount: 50, TransactionType: "EXPENSE", OccurredAt: day1},
+		// bob pays 30 for a taxi only alice took
+		{GroupID: taxi, FromUserID: alice, ToUserID: bob, Amount: 30, TransactionType: "EXPENSE", OccurredAt: day3},
+	}
+
+	res := Compute(items, []uuid.UUID{alice, bob}, Daily)
+	byUser := map[uuid.UUID]MemberStats{}
+	for _, s := range res {
+		byUser[s.UserID] = s
+	}
+
+	a := byUser[alice]
+	assert.Equal(t, int64(100), a.TotalPaid)
+	assert.Equal(t, int64(80), a.TotalConsumed)
+	assert.Equal(t, int64(20), a.NetBalance)
+	assert.InDelta(t, 80.0/130.0, a.SpendingShare, 1e-9)
+	assert.Equal(t, []ExpenseSummary{{GroupID: dinner, OccurredAt: day1, Amount: 100}}, a.LargestExpenses)
+	assert.Equal(t, []BalancePoint{
+		{Date: BucketStart(Daily, day1), Balance: 50},
+		{Date: BucketStart(Daily, day1.AddDate(0, 0, 1)), Balance: 50},
+		{Date: BucketStart(Daily, day3), Balance: 20},
+	}, a.BalanceSeries)
+
+	b := byUser[bob]
+	assert.Equal(t, int64(30), b.TotalPaid)
+	assert.Equal(t, int64(-20), b.NetBalance)
+}
+
+func TestBucketStart_Weekly(t *testing.T) {
+	sunday := time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), BucketStart(Weekly, sunday))
+}
+
+func TestCompute_SeriesCapped(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	start := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	items := []models.Item{
+		{FromUserID: bob, ToUserID: alice, Amount: 70, TransactionType: "EXPENSE", OccurredAt: start},
+		{FromUserID: bob, ToUserID: alice, Amount: 30, TransactionType: "EXPENSE", OccurredAt: end},
+	}
+
+	for _, s := range Compute(items, []uuid.UUID{alice, bob}, Daily) {
+		if s.UserID != alice {
+			continue
+		}
+		assert.Len(t, s.BalanceSeries, MaxSeriesPoints)
+		// the first bucket carries what built up before the series starts
+		assert.Equal(t, int64(70), s.BalanceSeries[0].Balance)
+		last := s.BalanceSeries[MaxSeriesPoints-1]
+		assert.Equal(t, BalancePoint{Date: BucketStart(Daily, end), Balance: 100}, last)
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore_Put_Get_Delete(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+
+	n, err := s.Put("room/blob", bytes.NewReader([]byte("receipt")))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), n)
+
+	r, err := s.Get("room/blob")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "receipt", string(data))
+
+	assert.NoError(t, s.Delete("room/blob"))
+	_, err = s.Get("room/blob")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestLocalStore_RejectsTraversal(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+
+	_, err = s.Put("../escape", bytes.NewReader(nil))
+	assert.Error(t, err)
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1000, 500)))
+
+	thumb, err := MakeThumbnail(&buf, ThumbnailMaxDim)
+	assert.NoError(t, err)
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(thumb))
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, 256, cfg.Width)
+	assert.Equal(t, 128, cfg.Height)
+}
+
+func TestMakeThumbnail_TooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+
+	// the header claims a 100000x100000 canvas for the same few bytes
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	_, err := MakeThumbnail(bytes.NewReader(data), ThumbnailMaxDim)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+const ThumbnailMaxDim = 256
+
+// ThumbnailMaxPixels caps the canvas of images that are decoded, as a small
+// file can declare a canvas far too large to hold in memory.
+const ThumbnailMaxPixels = 50_000_000
+
+var ErrImageTooLarge = errors.New("image too large")
+
+// MakeThumbnail decodes a GIF, JPEG or PNG image and returns a JPEG scaled
+// down so that neither side exceeds maxDim. Smaller images are only re-encoded.
+// Images of more than ThumbnailMaxPixels are refused before decoding.
+func MakeThumbnail(r io.Reader, maxDim int) ([]byte, error) {
+	// the header is read once to check the size, then again to decode
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > ThumbnailMaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(io.MultiReader(&header, r))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w > maxDim || h > maxDim {
+		if w >= h {
+			tw, th = maxDim, max(1, h*maxDim/w)
+		} else {
+			tw, th = max(1, w*maxDim/h), maxDim
+		}
+	}
+
+	// nearest neighbour is good enough for receipt previews
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		sy := bounds.Min.Y + y*h/th
+		for x := 0; x < tw; x++ {
+			sx := bounds.Min.X + x*w/tw
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -1,7 +1,92 @@
 import axios from 'axios';
+import Cookies from 'js-cookie';
 
 const api = axios.create({
     baseURL: 'http://158.69.215.13/tgt',
 });
 
+const SESSION_COOKIE = 'session_user';
+// access tokens are refreshed when they have less than this left, in seconds
+const REFRESH_MARGIN = 60;
+
+export const getSessionUser = () => {
+    const sessionUser = Cookies.get(SESSION_COOKIE);
+    return sessionUser ? JSON.parse(sessionUser) : null;
+};
+
+export const setSessionUser = (user) => {
+    Cookies.set(SESSION_COOKIE, JSON.stringify(user), { expires: 1 });
+};
+
+const expiresSoon = (jwt) => {
+    try {
+        const payload = JSON.parse(atob(jwt.split('.')[1].replace(/-/g, '+').replace(/_/g, '/')));
+        return payload.exp - Date.now() / 1000 < REFRESH_MARGIN;
+    } catch (e) {
+        return false;
+    }
+};
+
+// A refresh token can only be traded once, so callers refreshing at the same
+// time share one request.
+let refreshing = null;
+
+export const refreshSession = () => {
+    if (!refreshing) {
+        const user = getSessionUser();
+        if (!user || !user.refreshToken) {
+            return Promise.reject(new Error('NO_REFRESH_TOKEN'));
+        }
+        refreshing = axios.post(`${api.defaults.baseURL}/sessions/refresh`, { refresh_token: user.refreshToken })
+            .then(response => {
+                setSessionUser({ ...user, jwt: response.data.token, refreshToken: response.data.refreshToken });
+                return response.data.token;
+            })
+            .finally(() => {
+                refreshing = null;
+            });
+    }
+    return refreshing;
+};
+
+// currentToken returns an access token that is not about to expire.
+export const currentToken = () => {
+    const user = getSessionUser();
+    if (!user || !user.jwt) {
+        return Promise.resolve('');
+    }
+    if (user.refreshToken && expiresSoon(user.jwt)) {
+        return refreshSession().catch(() => user.jwt);
+    }
+    return Promise.resolve(user.jwt);
+};
+
+// Authenticated requests always go out with the latest access token, which is
+// refreshed shortly before it expires.
+api.interceptors.request.use(config => {
+    if (!config.headers.Authorization) {
+        return config;
+    }
+    return currentToken().then(jwt => {
+        if (jwt) {
+            config.headers.Authorization = `Bearer ${jwt}`;
+        }
+        return config;
+    });
+});
+
+// A request refused with an expired token is retried once after a refresh.
+api.interceptors.response.use(response => response, error => {
+    const { config, response } = error;
+    if (!response || response.status !== 401 || !config || config._retried || !config.headers.Authorization) {
+        return Promise.reject(error);
+    }
+    config._retried = true;
+    return refreshSession()
+        .then(jwt => {
+            config.headers.Authorization = `Bearer ${jwt}`;
+            return api(config);
+        }, () => Promise.reject(error));
+});
+
 export default api;
@@ -1,6 +1,5 @@
 import React, {useEffect, useState} from "react";
-import api from "../Api";
-import Cookies from "js-cookie";
+import api, { setSessionUser } from "../Api";
 import {useNavigate} from "react-router-dom";
 
 const registerErrorMap = new Map([
@@ -84,11 +83,12 @@ const LoginPage = () => {
                 setLoginUsername("");
                 setLoginPassword("");
 
-                Cookies.set('session_user', JSON.stringify({
+                setSessionUser({
                     userId: response.data.user.id,
                     username: response.data.user.name,
-                    jwt: response.data.token
-                }), { expires: 1 });
+                    jwt: response.data.token,
+                    refreshToken: response.data.refreshToken
+                });
 
                 navigate("/");
             })
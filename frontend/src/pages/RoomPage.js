@@ -1,6 +1,6 @@
 import React, { useEffect, useState } from 'react';
 import {redirect, useNavigate, useParams} from 'react-router-dom';
-import api from '../Api';
+import api, { currentToken } from '../Api';
 import Cookies from "js-cookie";
 import NotFoundPage from "./NotFoundPage";
 import LoadingPage from "./LoadingPage";
@@ -31,6 +31,7 @@ const RoomPage = () => {
     const [loggedUsername, setLoggedUsername] = useState('');
     const [loggedUserId, setLoggedUserId] = useState('');
     const [loggedJWT, setLoggedJWT] = useState('');
+    const [role, setRole] = useState('');
 
     const [globalError, setGlobalError] = useState('');
     const [newItemError, setNewItemError] = useState('');
@@ -50,6 +51,8 @@ const RoomPage = () => {
 
     const INVALID_TOKEN = "INVALID_TOKEN";
     const HTTP_UNAUTHORIZED = 401;
+    // delay before reopening a dropped event stream, in milliseconds
+    const SSE_RETRY_MS = 2000;
     const NAME_TRUNCATE_LENGTH = 20;
 
     const navigate = useNavigate();
@@ -73,12 +76,13 @@ const RoomPage = () => {
         setLoggedUserId(parsedUser.userId);
         setLoggedJWT(parsedUser.jwt);
 
-        api.get(`/rooms/${roomID}`, { headers: { Authorization: `Bearer ${parsedUser.jwt}` } })
+        api.get(`/rooms/${roomID}?view=full`, { headers: { Authorization: `Bearer ${parsedUser.jwt}` } })
             .then((response) => {
                 setRoomName(response.data.room.name);
                 setItems(response.data.items);
                 setNewAmounts(response.data.users.map((_) => ""))
                 setUsers(response.data.users);
+                setRole(response.data.role);
                 setSimplifiedItems(response.data.simplifiedItems);
             })
             .catch(error => {
@@ -92,8 +96,23 @@ const RoomPage = () => {
                 }
             })
 
-        const eventSource = new EventSource(`${api.defaults.baseURL}/rooms/${roomID}/sse?token=${encodeURIComponent(parsedUser.jwt)}`);
-        eventSource.onmessage = (event) => {
+        // the stream is reopened with a fresh access token whenever it drops,
+        // as the browser would otherwise reconnect with the expired one
+        let eventSource = null;
+        let closed = false;
+        const openEventSource = (jwt) => {
+            if (closed) {
+                return;
+            }
+            eventSource = new EventSource(`${api.defaults.baseURL}/rooms/${roomID}/sse?token=${encodeURIComponent(jwt)}`);
+            eventSource.onmessage = handleMessage;
+            eventSource.onerror = () => {
+                eventSource.close();
+                setTimeout(() => currentToken().then(openEventSource), SSE_RETRY_MS);
+            };
+        };
+
+        const handleMessage = (event) => {
             if (!event.data) {
                 setGlobalError("SERVER_ERROR");
                 return;
@@ -128,8 +147,13 @@ const RoomPage = () => {
             }
         };
 
+        currentToken().then(openEventSource);
+
         return () => {
-            eventSource.close();
+            closed = true;
+            if (eventSource) {
+                eventSource.close();
+            }
         };
     }, [roomID]);
 
@@ -381,8 +405,13 @@ const RoomPage = () => {
     }
 
     const handleSimplify = (algoType) => {
-        api.post(`/rooms/${roomID}/simplify?algo=${algoType}`, undefined,
-            { headers: { Authorization: `Bearer ${loggedJWT}` }})
+        const config = { headers: { Authorization: `Bearer ${loggedJWT}` }};
+        // ?algo= on its own is only a preview; admins save the choice with the room
+        const request = role === 'ADMIN'
+            ? api.patch(`/rooms/${roomID}`, { simplify_algo: String(algoType) }, config)
+                .then(() => api.post(`/rooms/${roomID}/simplify`, undefined, config))
+            : api.post(`/rooms/${roomID}/simplify?algo=${algoType}`, undefined, config);
+        request
             .then((response) => {
                 setSimplifiedItems(response.data.simplifiedItems);
             })